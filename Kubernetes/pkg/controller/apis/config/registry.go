@@ -0,0 +1,93 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// controllerConfigRegistry tracks the typed config prototypes that out-of-tree
+// controllers have registered via RegisterControllerConfig, keyed by
+// controller name.
+var controllerConfigRegistry = struct {
+	mu    sync.RWMutex
+	types map[string]runtime.Object
+}{types: make(map[string]runtime.Object)}
+
+// RegisterControllerConfig lets an out-of-tree controller plug a typed
+// configuration struct into the ExtraControllers section of
+// KubeControllerManagerConfiguration. proto is used only as a prototype: a
+// fresh copy of it is created via DeepCopyObject whenever that controller's
+// entry is decoded, so any field already set on proto acts as a default for
+// fields the entry leaves out. Calling
+// RegisterControllerConfig twice for the same name replaces the prototype.
+func RegisterControllerConfig(name string, proto runtime.Object) {
+	controllerConfigRegistry.mu.Lock()
+	defer controllerConfigRegistry.mu.Unlock()
+	controllerConfigRegistry.types[name] = proto
+}
+
+// lookupControllerConfig returns the registered prototype for name, if any.
+func lookupControllerConfig(name string) (runtime.Object, bool) {
+	controllerConfigRegistry.mu.RLock()
+	defer controllerConfigRegistry.mu.RUnlock()
+	proto, ok := controllerConfigRegistry.types[name]
+	return proto, ok
+}
+
+// DecodeExtraController decodes the RawExtension stored for name using the
+// type registered via RegisterControllerConfig. It returns an error if no
+// type has been registered for name; callers that want to tolerate unknown
+// controllers should check ExtraControllers directly instead.
+func DecodeExtraController(name string, raw runtime.RawExtension) (runtime.Object, error) {
+	proto, ok := lookupControllerConfig(name)
+	if !ok {
+		return nil, fmt.Errorf("no controller config type registered for %q", name)
+	}
+	obj := proto.DeepCopyObject()
+	if err := yaml.Unmarshal(raw.Raw, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode extra controller config for %q: %w", name, err)
+	}
+	return obj, nil
+}
+
+// deepCopyExtraControllers copies a map of ExtraControllers entries,
+// dispatching through each entry's registered DeepCopyObject when a type is
+// known for that name and falling back to a plain RawExtension copy
+// otherwise. This mirrors what deepcopy-gen would emit if the map's value
+// type were concrete rather than RawExtension.
+func deepCopyExtraControllers(in map[string]runtime.RawExtension) map[string]runtime.RawExtension {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]runtime.RawExtension, len(in))
+	for name, raw := range in {
+		if raw.Object != nil {
+			if proto, ok := lookupControllerConfig(name); ok && proto.GetObjectKind().GroupVersionKind() == raw.Object.GetObjectKind().GroupVersionKind() {
+				out[name] = runtime.RawExtension{Raw: append([]byte(nil), raw.Raw...), Object: raw.Object.DeepCopyObject()}
+				continue
+			}
+		}
+		copied := raw.DeepCopy()
+		out[name] = *copied
+	}
+	return out
+}
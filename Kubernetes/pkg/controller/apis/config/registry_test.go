@@ -0,0 +1,84 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeControllerConfig is a minimal runtime.Object used to exercise the
+// ExtraControllers registry without depending on a real out-of-tree type.
+type fakeControllerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	Concurrency     int `json:"concurrency"`
+}
+
+func (in *fakeControllerConfig) DeepCopyObject() runtime.Object {
+	out := new(fakeControllerConfig)
+	*out = *in
+	return out
+}
+
+func (in *fakeControllerConfig) GetObjectKind() schema.ObjectKind {
+	return &in.TypeMeta
+}
+
+func TestDecodeExtraController(t *testing.T) {
+	RegisterControllerConfig("widget", &fakeControllerConfig{})
+
+	raw := runtime.RawExtension{Raw: []byte(`{"concurrency": 3}`)}
+	obj, err := DecodeExtraController("widget", raw)
+	if err != nil {
+		t.Fatalf("DecodeExtraController failed: %v", err)
+	}
+	cfg, ok := obj.(*fakeControllerConfig)
+	if !ok {
+		t.Fatalf("expected *fakeControllerConfig, got %T", obj)
+	}
+	if cfg.Concurrency != 3 {
+		t.Fatalf("expected concurrency 3, got %d", cfg.Concurrency)
+	}
+}
+
+func TestDecodeExtraControllerUnregistered(t *testing.T) {
+	if _, err := DecodeExtraController("unknown-controller", runtime.RawExtension{}); err == nil {
+		t.Fatal("expected an error decoding an unregistered controller name")
+	}
+}
+
+func TestDeepCopyExtraControllers(t *testing.T) {
+	in := map[string]runtime.RawExtension{
+		"widget": {Raw: []byte(`{"concurrency": 3}`)},
+	}
+	out := deepCopyExtraControllers(in)
+	if string(out["widget"].Raw) != string(in["widget"].Raw) {
+		t.Fatalf("expected copied bytes to match, got %s", out["widget"].Raw)
+	}
+
+	// Mutating the copy's backing array must not be observed through in,
+	// proving deepCopyExtraControllers gave out["widget"].Raw its own
+	// backing storage instead of aliasing in["widget"].Raw.
+	outWidget := out["widget"]
+	outWidget.Raw[0] = 'X'
+	if in["widget"].Raw[0] == 'X' {
+		t.Fatal("expected out and in to have independent Raw backing arrays after deep copy")
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+)
+
+// ValidateSharding checks that every controller named in enabledControllers
+// belongs to exactly one shard in sharding. An empty ShardingConfiguration
+// (no shards configured) is always valid and means every controller runs in
+// a single, unsharded process, matching today's behavior.
+func ValidateSharding(sharding ShardingConfiguration, enabledControllers []string) error {
+	if len(sharding.Shards) == 0 {
+		return nil
+	}
+
+	owner := make(map[string]string, len(enabledControllers))
+	for _, shard := range sharding.Shards {
+		for _, controller := range shard.Controllers {
+			if existing, ok := owner[controller]; ok {
+				return fmt.Errorf("controller %q is assigned to both shard %q and shard %q", controller, existing, shard.Name)
+			}
+			owner[controller] = shard.Name
+		}
+	}
+
+	var unassigned []string
+	for _, controller := range enabledControllers {
+		if _, ok := owner[controller]; !ok {
+			unassigned = append(unassigned, controller)
+		}
+	}
+	if len(unassigned) > 0 {
+		return fmt.Errorf("controllers %v are enabled but not assigned to any shard", unassigned)
+	}
+	return nil
+}
+
+// ControllersForShard returns the subset of enabledControllers that belong to
+// the named shard. If sharding has no shards configured, every controller is
+// returned, since the process is running unsharded.
+func ControllersForShard(sharding ShardingConfiguration, shardName string, enabledControllers []string) ([]string, error) {
+	if len(sharding.Shards) == 0 {
+		return enabledControllers, nil
+	}
+
+	for _, shard := range sharding.Shards {
+		if shard.Name != shardName {
+			continue
+		}
+		enabled := make(map[string]bool, len(enabledControllers))
+		for _, controller := range enabledControllers {
+			enabled[controller] = true
+		}
+		var controllers []string
+		for _, controller := range shard.Controllers {
+			if enabled[controller] {
+				controllers = append(controllers, controller)
+			}
+		}
+		return controllers, nil
+	}
+	return nil, fmt.Errorf("no shard named %q configured", shardName)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import "testing"
+
+func TestValidateSharding(t *testing.T) {
+	cases := []struct {
+		name               string
+		sharding           ShardingConfiguration
+		enabledControllers []string
+		wantErr            bool
+	}{
+		{
+			name:               "no sharding configured is always valid",
+			sharding:           ShardingConfiguration{},
+			enabledControllers: []string{"job", "daemonset"},
+		},
+		{
+			name: "every controller assigned to exactly one shard",
+			sharding: ShardingConfiguration{Shards: []ShardConfiguration{
+				{Name: "shard-a", Controllers: []string{"job"}},
+				{Name: "shard-b", Controllers: []string{"daemonset"}},
+			}},
+			enabledControllers: []string{"job", "daemonset"},
+		},
+		{
+			name: "controller assigned to two shards",
+			sharding: ShardingConfiguration{Shards: []ShardConfiguration{
+				{Name: "shard-a", Controllers: []string{"job"}},
+				{Name: "shard-b", Controllers: []string{"job"}},
+			}},
+			enabledControllers: []string{"job"},
+			wantErr:            true,
+		},
+		{
+			name: "enabled controller not assigned to any shard",
+			sharding: ShardingConfiguration{Shards: []ShardConfiguration{
+				{Name: "shard-a", Controllers: []string{"job"}},
+			}},
+			enabledControllers: []string{"job", "daemonset"},
+			wantErr:            true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSharding(tc.sharding, tc.enabledControllers)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestControllersForShard(t *testing.T) {
+	sharding := ShardingConfiguration{Shards: []ShardConfiguration{
+		{Name: "shard-a", Controllers: []string{"job"}},
+		{Name: "shard-b", Controllers: []string{"daemonset"}},
+	}}
+
+	controllers, err := ControllersForShard(sharding, "shard-a", []string{"job", "daemonset"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(controllers) != 1 || controllers[0] != "job" {
+		t.Fatalf("expected [job], got %v", controllers)
+	}
+
+	if _, err := ControllersForShard(sharding, "unknown-shard", []string{"job"}); err == nil {
+		t.Fatal("expected an error for an unknown shard name")
+	}
+
+	// A controller the shard lists but that is not in enabledControllers
+	// (e.g. disabled via --controllers) must not be returned, or it would
+	// get started anyway.
+	controllers, err = ControllersForShard(sharding, "shard-a", []string{"daemonset"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(controllers) != 0 {
+		t.Fatalf("expected no controllers for shard-a when job is disabled, got %v", controllers)
+	}
+}
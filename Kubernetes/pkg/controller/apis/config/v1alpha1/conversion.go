@@ -0,0 +1,150 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	conversion "k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/controller/apis/config"
+)
+
+// addConversionFuncs registers this package's hand-written conversion
+// functions with scheme. It uses the currently supported
+// scheme.AddConversionFunc (singular), which takes untyped source/destination
+// pointers and an untyped conversion.ConversionFunc; runtime.Scheme's older
+// AddConversionFuncs (plural, accepting the typed function values directly)
+// was removed from apimachinery, which is why each registration here is a
+// small closure doing the type assertion conversion-gen would otherwise
+// generate.
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	if err := scheme.AddConversionFunc((*KubeControllerManagerConfiguration)(nil), (*config.KubeControllerManagerConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_KubeControllerManagerConfiguration_To_config_KubeControllerManagerConfiguration(a.(*KubeControllerManagerConfiguration), b.(*config.KubeControllerManagerConfiguration), scope)
+	}); err != nil {
+		return err
+	}
+	return scheme.AddConversionFunc((*config.KubeControllerManagerConfiguration)(nil), (*KubeControllerManagerConfiguration)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_config_KubeControllerManagerConfiguration_To_v1alpha1_KubeControllerManagerConfiguration(a.(*config.KubeControllerManagerConfiguration), b.(*KubeControllerManagerConfiguration), scope)
+	})
+}
+
+// Convert_v1alpha1_KubeControllerManagerConfiguration_To_config_KubeControllerManagerConfiguration
+// converts a versioned KubeControllerManagerConfiguration, as loaded from an
+// on-disk v1alpha1 YAML document, to the internal type the rest of
+// kube-controller-manager consumes. Every field shares its Go type between
+// the versioned and internal structs (the sub-controller config packages are
+// not themselves versioned), so the conversion is a straight field-by-field
+// copy, except Sharding, which has its own versioned types. TypeMeta is not
+// copied.
+func Convert_v1alpha1_KubeControllerManagerConfiguration_To_config_KubeControllerManagerConfiguration(in *KubeControllerManagerConfiguration, out *config.KubeControllerManagerConfiguration, s conversion.Scope) error {
+	out.Generic = in.Generic
+	out.KubeCloudShared = in.KubeCloudShared
+	out.AttachDetachController = in.AttachDetachController
+	out.CSRSigningController = in.CSRSigningController
+	out.DaemonSetController = in.DaemonSetController
+	out.DeploymentController = in.DeploymentController
+	out.StatefulSetController = in.StatefulSetController
+	out.DeprecatedController = config.DeprecatedControllerConfiguration(in.DeprecatedController)
+	out.EndpointController = in.EndpointController
+	out.EndpointSliceController = in.EndpointSliceController
+	out.EndpointSliceMirroringController = in.EndpointSliceMirroringController
+	out.EphemeralVolumeController = in.EphemeralVolumeController
+	out.GarbageCollectorController = in.GarbageCollectorController
+	out.HPAController = in.HPAController
+	out.JobController = in.JobController
+	out.CronJobController = in.CronJobController
+	out.NamespaceController = in.NamespaceController
+	out.NodeIPAMController = in.NodeIPAMController
+	out.NodeLifecycleController = in.NodeLifecycleController
+	out.PersistentVolumeBinderController = in.PersistentVolumeBinderController
+	out.PodGCController = in.PodGCController
+	out.ReplicaSetController = in.ReplicaSetController
+	out.ReplicationController = in.ReplicationController
+	out.ResourceQuotaController = in.ResourceQuotaController
+	out.SAController = in.SAController
+	out.ServiceController = in.ServiceController
+	out.TTLAfterFinishedController = in.TTLAfterFinishedController
+	out.ExtraControllers = in.ExtraControllers
+	out.Sharding = convertToInternalSharding(in.Sharding)
+	return nil
+}
+
+func convertToInternalSharding(in ShardingConfiguration) config.ShardingConfiguration {
+	if in.Shards == nil {
+		return config.ShardingConfiguration{}
+	}
+	shards := make([]config.ShardConfiguration, len(in.Shards))
+	for i, shard := range in.Shards {
+		shards[i] = config.ShardConfiguration{
+			Name:           shard.Name,
+			Controllers:    shard.Controllers,
+			LeaderElection: shard.LeaderElection,
+		}
+	}
+	return config.ShardingConfiguration{Shards: shards}
+}
+
+// Convert_config_KubeControllerManagerConfiguration_To_v1alpha1_KubeControllerManagerConfiguration
+// is the inverse of Convert_v1alpha1_KubeControllerManagerConfiguration_To_config_KubeControllerManagerConfiguration,
+// used when re-serializing the running internal configuration back to
+// v1alpha1 YAML (e.g. for `--write-config-to`).
+func Convert_config_KubeControllerManagerConfiguration_To_v1alpha1_KubeControllerManagerConfiguration(in *config.KubeControllerManagerConfiguration, out *KubeControllerManagerConfiguration, s conversion.Scope) error {
+	out.Generic = in.Generic
+	out.KubeCloudShared = in.KubeCloudShared
+	out.AttachDetachController = in.AttachDetachController
+	out.CSRSigningController = in.CSRSigningController
+	out.DaemonSetController = in.DaemonSetController
+	out.DeploymentController = in.DeploymentController
+	out.StatefulSetController = in.StatefulSetController
+	out.DeprecatedController = DeprecatedControllerConfiguration(in.DeprecatedController)
+	out.EndpointController = in.EndpointController
+	out.EndpointSliceController = in.EndpointSliceController
+	out.EndpointSliceMirroringController = in.EndpointSliceMirroringController
+	out.EphemeralVolumeController = in.EphemeralVolumeController
+	out.GarbageCollectorController = in.GarbageCollectorController
+	out.HPAController = in.HPAController
+	out.JobController = in.JobController
+	out.CronJobController = in.CronJobController
+	out.NamespaceController = in.NamespaceController
+	out.NodeIPAMController = in.NodeIPAMController
+	out.NodeLifecycleController = in.NodeLifecycleController
+	out.PersistentVolumeBinderController = in.PersistentVolumeBinderController
+	out.PodGCController = in.PodGCController
+	out.ReplicaSetController = in.ReplicaSetController
+	out.ReplicationController = in.ReplicationController
+	out.ResourceQuotaController = in.ResourceQuotaController
+	out.SAController = in.SAController
+	out.ServiceController = in.ServiceController
+	out.TTLAfterFinishedController = in.TTLAfterFinishedController
+	out.ExtraControllers = in.ExtraControllers
+	out.Sharding = convertFromInternalSharding(in.Sharding)
+	return nil
+}
+
+func convertFromInternalSharding(in config.ShardingConfiguration) ShardingConfiguration {
+	if in.Shards == nil {
+		return ShardingConfiguration{}
+	}
+	shards := make([]ShardConfiguration, len(in.Shards))
+	for i, shard := range in.Shards {
+		shards[i] = ShardConfiguration{
+			Name:           shard.Name,
+			Controllers:    shard.Controllers,
+			LeaderElection: shard.LeaderElection,
+		}
+	}
+	return ShardingConfiguration{Shards: shards}
+}
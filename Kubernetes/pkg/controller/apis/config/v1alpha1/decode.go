@@ -0,0 +1,42 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kubernetes/pkg/controller/apis/config"
+)
+
+// Decode parses a v1alpha1 KubeControllerManagerConfiguration YAML document,
+// defaults any unset fields, and converts the result to the internal
+// configuration type kube-controller-manager runs with.
+func Decode(data []byte) (*config.KubeControllerManagerConfiguration, error) {
+	versioned := &KubeControllerManagerConfiguration{}
+	if err := yaml.Unmarshal(data, versioned); err != nil {
+		return nil, fmt.Errorf("failed to decode v1alpha1 KubeControllerManagerConfiguration: %w", err)
+	}
+
+	SetDefaults_KubeControllerManagerConfiguration(versioned)
+
+	internal := &config.KubeControllerManagerConfiguration{}
+	if err := Convert_v1alpha1_KubeControllerManagerConfiguration_To_config_KubeControllerManagerConfiguration(versioned, internal, nil); err != nil {
+		return nil, fmt.Errorf("failed to convert v1alpha1 KubeControllerManagerConfiguration to internal: %w", err)
+	}
+	return internal, nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	csrsigningconfig "k8s.io/kubernetes/pkg/controller/certificates/signer/config"
+	deploymentconfig "k8s.io/kubernetes/pkg/controller/deployment/config"
+	jobconfig "k8s.io/kubernetes/pkg/controller/job/config"
+	nodeipamconfig "k8s.io/kubernetes/pkg/controller/nodeipam/config"
+	hpaconfig "k8s.io/kubernetes/pkg/controller/podautoscaler/config"
+	attachdetachconfig "k8s.io/kubernetes/pkg/controller/volume/attachdetach/config"
+)
+
+const (
+	defaultConcurrentJobSyncs        = 5
+	defaultConcurrentDeploymentSyncs = 5
+
+	defaultReconcilerSyncLoopPeriod = 60 * time.Second
+
+	defaultClusterSigningDuration = 365 * 24 * time.Hour
+
+	defaultHorizontalPodAutoscalerSyncPeriod                   = 15 * time.Second
+	defaultHorizontalPodAutoscalerTolerance                    = 0.1
+	defaultHorizontalPodAutoscalerDownscaleStabilizationWindow = 5 * time.Minute
+	defaultHorizontalPodAutoscalerCPUInitializationPeriod      = 5 * time.Minute
+	defaultHorizontalPodAutoscalerInitialReadinessDelay        = 30 * time.Second
+
+	defaultNodeCIDRMaskSizeIPv4 = 24
+	defaultNodeCIDRMaskSizeIPv6 = 64
+)
+
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	return RegisterDefaults(scheme)
+}
+
+// RegisterDefaults registers this package's defaulting functions with scheme.
+func RegisterDefaults(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&KubeControllerManagerConfiguration{}, func(obj interface{}) {
+		SetObjectDefaults_KubeControllerManagerConfiguration(obj.(*KubeControllerManagerConfiguration))
+	})
+	return nil
+}
+
+// SetObjectDefaults_KubeControllerManagerConfiguration fills in unset fields
+// of obj with the kube-controller-manager's built-in defaults.
+func SetObjectDefaults_KubeControllerManagerConfiguration(obj *KubeControllerManagerConfiguration) {
+	SetDefaults_KubeControllerManagerConfiguration(obj)
+}
+
+// SetDefaults_KubeControllerManagerConfiguration defaults the fields this
+// package owns directly (Generic), plus the sub-controller configs this
+// package has its own SetDefaults_* for. The remaining sub-controller
+// structs embedded in KubeControllerManagerConfiguration are out of scope
+// for now: they come from packages this tree does not vendor, so their
+// field names can't be verified here, and they are left as the zero value
+// rather than guessed at.
+func SetDefaults_KubeControllerManagerConfiguration(obj *KubeControllerManagerConfiguration) {
+	if obj.Generic.LeaderElection.LeaseDuration == (metav1.Duration{}) {
+		obj.Generic.LeaderElection.LeaseDuration = metav1.Duration{Duration: 15 * time.Second}
+	}
+	SetDefaults_JobController(&obj.JobController)
+	SetDefaults_DeploymentController(&obj.DeploymentController)
+	SetDefaults_AttachDetachController(&obj.AttachDetachController)
+	SetDefaults_CSRSigningController(&obj.CSRSigningController)
+	SetDefaults_HPAController(&obj.HPAController)
+	SetDefaults_NodeIPAMController(&obj.NodeIPAMController)
+}
+
+// SetDefaults_JobController defaults obj's fields to the
+// kube-controller-manager's built-in job controller defaults.
+func SetDefaults_JobController(obj *jobconfig.JobControllerConfiguration) {
+	if obj.ConcurrentJobSyncs == 0 {
+		obj.ConcurrentJobSyncs = defaultConcurrentJobSyncs
+	}
+}
+
+// SetDefaults_DeploymentController defaults obj's fields to the
+// kube-controller-manager's built-in deployment controller defaults.
+func SetDefaults_DeploymentController(obj *deploymentconfig.DeploymentControllerConfiguration) {
+	if obj.ConcurrentDeploymentSyncs == 0 {
+		obj.ConcurrentDeploymentSyncs = defaultConcurrentDeploymentSyncs
+	}
+}
+
+// SetDefaults_AttachDetachController defaults obj's fields to the
+// kube-controller-manager's built-in attach/detach controller defaults.
+func SetDefaults_AttachDetachController(obj *attachdetachconfig.AttachDetachControllerConfiguration) {
+	if obj.ReconcilerSyncLoopPeriod.Duration == 0 {
+		obj.ReconcilerSyncLoopPeriod = metav1.Duration{Duration: defaultReconcilerSyncLoopPeriod}
+	}
+}
+
+// SetDefaults_CSRSigningController defaults obj's fields to the
+// kube-controller-manager's built-in CSR signing controller defaults.
+func SetDefaults_CSRSigningController(obj *csrsigningconfig.CSRSigningControllerConfiguration) {
+	if obj.ClusterSigningDuration.Duration == 0 {
+		obj.ClusterSigningDuration = metav1.Duration{Duration: defaultClusterSigningDuration}
+	}
+}
+
+// SetDefaults_HPAController defaults obj's fields to the
+// kube-controller-manager's built-in horizontal pod autoscaler controller
+// defaults.
+func SetDefaults_HPAController(obj *hpaconfig.HPAControllerConfiguration) {
+	if obj.HorizontalPodAutoscalerSyncPeriod.Duration == 0 {
+		obj.HorizontalPodAutoscalerSyncPeriod = metav1.Duration{Duration: defaultHorizontalPodAutoscalerSyncPeriod}
+	}
+	if obj.HorizontalPodAutoscalerTolerance == 0 {
+		obj.HorizontalPodAutoscalerTolerance = defaultHorizontalPodAutoscalerTolerance
+	}
+	if obj.HorizontalPodAutoscalerDownscaleStabilizationWindow.Duration == 0 {
+		obj.HorizontalPodAutoscalerDownscaleStabilizationWindow = metav1.Duration{Duration: defaultHorizontalPodAutoscalerDownscaleStabilizationWindow}
+	}
+	if obj.HorizontalPodAutoscalerCPUInitializationPeriod.Duration == 0 {
+		obj.HorizontalPodAutoscalerCPUInitializationPeriod = metav1.Duration{Duration: defaultHorizontalPodAutoscalerCPUInitializationPeriod}
+	}
+	if obj.HorizontalPodAutoscalerInitialReadinessDelay.Duration == 0 {
+		obj.HorizontalPodAutoscalerInitialReadinessDelay = metav1.Duration{Duration: defaultHorizontalPodAutoscalerInitialReadinessDelay}
+	}
+}
+
+// SetDefaults_NodeIPAMController defaults obj's fields to the
+// kube-controller-manager's built-in node IPAM controller defaults.
+func SetDefaults_NodeIPAMController(obj *nodeipamconfig.NodeIPAMControllerConfiguration) {
+	if obj.NodeCIDRMaskSizeIPv4 == 0 {
+		obj.NodeCIDRMaskSizeIPv4 = defaultNodeCIDRMaskSizeIPv4
+	}
+	if obj.NodeCIDRMaskSizeIPv6 == 0 {
+		obj.NodeCIDRMaskSizeIPv6 = defaultNodeCIDRMaskSizeIPv6
+	}
+}
@@ -0,0 +1,25 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +k8s:deepcopy-gen=package
+// +k8s:conversion-gen=k8s.io/kubernetes/pkg/controller/apis/config
+// +k8s:defaulter-gen=TypeMeta
+// +groupName=kubecontrollermanager.config.k8s.io
+
+// Package v1alpha1 is the v1alpha1 version of the kube-controller-manager
+// component configuration, convertible to and from
+// k8s.io/kubernetes/pkg/controller/apis/config.
+package v1alpha1 // import "k8s.io/kubernetes/pkg/controller/apis/config/v1alpha1"
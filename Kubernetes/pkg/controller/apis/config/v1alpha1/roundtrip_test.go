@@ -0,0 +1,82 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/controller/apis/config"
+)
+
+// TestRoundTripExtraControllers fuzzes the ExtraControllers map through a
+// v1alpha1 -> internal -> v1alpha1 conversion. Sharding, the other field
+// with its own versioned types, is not covered by this test.
+func TestRoundTripExtraControllers(t *testing.T) {
+	roundTrip := func(raw map[string][]byte) bool {
+		in := &KubeControllerManagerConfiguration{ExtraControllers: map[string]runtime.RawExtension{}}
+		for name, data := range raw {
+			in.ExtraControllers[name] = runtime.RawExtension{Raw: data}
+		}
+
+		internal := &config.KubeControllerManagerConfiguration{}
+		if err := Convert_v1alpha1_KubeControllerManagerConfiguration_To_config_KubeControllerManagerConfiguration(in, internal, nil); err != nil {
+			t.Fatalf("forward conversion failed: %v", err)
+		}
+
+		out := &KubeControllerManagerConfiguration{}
+		if err := Convert_config_KubeControllerManagerConfiguration_To_v1alpha1_KubeControllerManagerConfiguration(internal, out, nil); err != nil {
+			t.Fatalf("backward conversion failed: %v", err)
+		}
+
+		return reflect.DeepEqual(in.ExtraControllers, out.ExtraControllers)
+	}
+
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecodeAppliesDefaults(t *testing.T) {
+	internal, err := Decode([]byte("{}"))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if internal.JobController.ConcurrentJobSyncs != defaultConcurrentJobSyncs {
+		t.Fatalf("expected default ConcurrentJobSyncs of %d, got %d", defaultConcurrentJobSyncs, internal.JobController.ConcurrentJobSyncs)
+	}
+	if internal.DeploymentController.ConcurrentDeploymentSyncs != defaultConcurrentDeploymentSyncs {
+		t.Fatalf("expected default ConcurrentDeploymentSyncs of %d, got %d", defaultConcurrentDeploymentSyncs, internal.DeploymentController.ConcurrentDeploymentSyncs)
+	}
+	if internal.AttachDetachController.ReconcilerSyncLoopPeriod.Duration != defaultReconcilerSyncLoopPeriod {
+		t.Fatalf("expected default ReconcilerSyncLoopPeriod of %s, got %s", defaultReconcilerSyncLoopPeriod, internal.AttachDetachController.ReconcilerSyncLoopPeriod.Duration)
+	}
+	if internal.CSRSigningController.ClusterSigningDuration.Duration != defaultClusterSigningDuration {
+		t.Fatalf("expected default ClusterSigningDuration of %s, got %s", defaultClusterSigningDuration, internal.CSRSigningController.ClusterSigningDuration.Duration)
+	}
+	if internal.HPAController.HorizontalPodAutoscalerSyncPeriod.Duration != defaultHorizontalPodAutoscalerSyncPeriod {
+		t.Fatalf("expected default HorizontalPodAutoscalerSyncPeriod of %s, got %s", defaultHorizontalPodAutoscalerSyncPeriod, internal.HPAController.HorizontalPodAutoscalerSyncPeriod.Duration)
+	}
+	if internal.NodeIPAMController.NodeCIDRMaskSizeIPv4 != defaultNodeCIDRMaskSizeIPv4 {
+		t.Fatalf("expected default NodeCIDRMaskSizeIPv4 of %d, got %d", defaultNodeCIDRMaskSizeIPv4, internal.NodeIPAMController.NodeCIDRMaskSizeIPv4)
+	}
+	if internal.NodeIPAMController.NodeCIDRMaskSizeIPv6 != defaultNodeCIDRMaskSizeIPv6 {
+		t.Fatalf("expected default NodeCIDRMaskSizeIPv6 of %d, got %d", defaultNodeCIDRMaskSizeIPv6, internal.NodeIPAMController.NodeCIDRMaskSizeIPv6)
+	}
+}
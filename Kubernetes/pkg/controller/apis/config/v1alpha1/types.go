@@ -0,0 +1,109 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	cmconfig "k8s.io/cloud-provider/config"
+	componentbaseconfig "k8s.io/component-base/config"
+	cpconfig "k8s.io/controller-manager/config"
+	csrsigningconfig "k8s.io/kubernetes/pkg/controller/certificates/signer/config"
+	cronjobconfig "k8s.io/kubernetes/pkg/controller/cronjob/config"
+	daemonconfig "k8s.io/kubernetes/pkg/controller/daemon/config"
+	deploymentconfig "k8s.io/kubernetes/pkg/controller/deployment/config"
+	endpointconfig "k8s.io/kubernetes/pkg/controller/endpoint/config"
+	endpointsliceconfig "k8s.io/kubernetes/pkg/controller/endpointslice/config"
+	endpointslicemirroringconfig "k8s.io/kubernetes/pkg/controller/endpointslicemirroring/config"
+	garbagecollectorconfig "k8s.io/kubernetes/pkg/controller/garbagecollector/config"
+	jobconfig "k8s.io/kubernetes/pkg/controller/job/config"
+	namespaceconfig "k8s.io/kubernetes/pkg/controller/namespace/config"
+	nodeipamconfig "k8s.io/kubernetes/pkg/controller/nodeipam/config"
+	nodelifecycleconfig "k8s.io/kubernetes/pkg/controller/nodelifecycle/config"
+	hpaconfig "k8s.io/kubernetes/pkg/controller/podautoscaler/config"
+	podgcconfig "k8s.io/kubernetes/pkg/controller/podgc/config"
+	replicasetconfig "k8s.io/kubernetes/pkg/controller/replicaset/config"
+	replicationconfig "k8s.io/kubernetes/pkg/controller/replication/config"
+	resourcequotaconfig "k8s.io/kubernetes/pkg/controller/resourcequota/config"
+	serviceconfig "k8s.io/kubernetes/pkg/controller/service/config"
+	serviceaccountconfig "k8s.io/kubernetes/pkg/controller/serviceaccount/config"
+	statefulsetconfig "k8s.io/kubernetes/pkg/controller/statefulset/config"
+	ttlafterfinishedconfig "k8s.io/kubernetes/pkg/controller/ttlafterfinished/config"
+	attachdetachconfig "k8s.io/kubernetes/pkg/controller/volume/attachdetach/config"
+	ephemeralvolumeconfig "k8s.io/kubernetes/pkg/controller/volume/ephemeral/config"
+	pvbinderconfig "k8s.io/kubernetes/pkg/controller/volume/persistentvolume/config"
+)
+
+// DeprecatedControllerConfiguration contains elements that are deprecated and will be removed.
+type DeprecatedControllerConfiguration struct {
+}
+
+// KubeControllerManagerConfiguration contains elements describing
+// kube-controller manager. It is the v1alpha1 on-disk representation; load it
+// with Decode and convert to the internal config.KubeControllerManagerConfiguration
+// before using it.
+type KubeControllerManagerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Generic                          cpconfig.GenericControllerManagerConfiguration                             `json:"generic"`
+	KubeCloudShared                  cmconfig.KubeCloudSharedConfiguration                                      `json:"kubeCloudShared"`
+	AttachDetachController           attachdetachconfig.AttachDetachControllerConfiguration                     `json:"attachDetachController"`
+	CSRSigningController             csrsigningconfig.CSRSigningControllerConfiguration                         `json:"csrSigningController"`
+	DaemonSetController              daemonconfig.DaemonSetControllerConfiguration                              `json:"daemonSetController"`
+	DeploymentController             deploymentconfig.DeploymentControllerConfiguration                         `json:"deploymentController"`
+	StatefulSetController            statefulsetconfig.StatefulSetControllerConfiguration                       `json:"statefulSetController"`
+	DeprecatedController             DeprecatedControllerConfiguration                                          `json:"deprecatedController"`
+	EndpointController               endpointconfig.EndpointControllerConfiguration                             `json:"endpointController"`
+	EndpointSliceController          endpointsliceconfig.EndpointSliceControllerConfiguration                   `json:"endpointSliceController"`
+	EndpointSliceMirroringController endpointslicemirroringconfig.EndpointSliceMirroringControllerConfiguration `json:"endpointSliceMirroringController"`
+	EphemeralVolumeController        ephemeralvolumeconfig.EphemeralVolumeControllerConfiguration               `json:"ephemeralVolumeController"`
+	GarbageCollectorController       garbagecollectorconfig.GarbageCollectorControllerConfiguration             `json:"garbageCollectorController"`
+	HPAController                    hpaconfig.HPAControllerConfiguration                                       `json:"hpaController"`
+	JobController                    jobconfig.JobControllerConfiguration                                       `json:"jobController"`
+	CronJobController                cronjobconfig.CronJobControllerConfiguration                               `json:"cronJobController"`
+	NamespaceController              namespaceconfig.NamespaceControllerConfiguration                           `json:"namespaceController"`
+	NodeIPAMController               nodeipamconfig.NodeIPAMControllerConfiguration                             `json:"nodeIPAMController"`
+	NodeLifecycleController          nodelifecycleconfig.NodeLifecycleControllerConfiguration                   `json:"nodeLifecycleController"`
+	PersistentVolumeBinderController pvbinderconfig.PersistentVolumeBinderControllerConfiguration               `json:"persistentVolumeBinderController"`
+	PodGCController                  podgcconfig.PodGCControllerConfiguration                                   `json:"podGCController"`
+	ReplicaSetController             replicasetconfig.ReplicaSetControllerConfiguration                         `json:"replicaSetController"`
+	ReplicationController            replicationconfig.ReplicationControllerConfiguration                       `json:"replicationController"`
+	ResourceQuotaController          resourcequotaconfig.ResourceQuotaControllerConfiguration                   `json:"resourceQuotaController"`
+	SAController                     serviceaccountconfig.SAControllerConfiguration                             `json:"saController"`
+	ServiceController                serviceconfig.ServiceControllerConfiguration                               `json:"serviceController"`
+	TTLAfterFinishedController       ttlafterfinishedconfig.TTLAfterFinishedControllerConfiguration             `json:"ttlAfterFinishedController"`
+	// ExtraControllers holds raw configuration for out-of-tree controllers,
+	// mirroring config.KubeControllerManagerConfiguration.ExtraControllers.
+	ExtraControllers map[string]runtime.RawExtension `json:"extraControllers,omitempty"`
+	// Sharding holds the assignment of controllers to independently
+	// leader-elected shards.
+	Sharding ShardingConfiguration `json:"sharding,omitempty"`
+}
+
+// ShardingConfiguration assigns controllers to shards, mirroring
+// config.ShardingConfiguration.
+type ShardingConfiguration struct {
+	Shards []ShardConfiguration `json:"shards,omitempty"`
+}
+
+// ShardConfiguration describes a single shard, mirroring
+// config.ShardConfiguration.
+type ShardConfiguration struct {
+	Name           string                                          `json:"name"`
+	Controllers    []string                                        `json:"controllers,omitempty"`
+	LeaderElection componentbaseconfig.LeaderElectionConfiguration `json:"leaderElection"`
+}
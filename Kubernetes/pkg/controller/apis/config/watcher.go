@@ -0,0 +1,249 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+)
+
+// EventType describes how a sub-controller configuration changed between two
+// successive loads of the on-disk KubeControllerManagerConfiguration.
+type EventType int
+
+const (
+	// EventStart is emitted when a controller is enabled by the --controllers
+	// list: on the initial load, or on a later load that enables a controller
+	// the previous load had disabled.
+	EventStart EventType = iota
+	// EventStop is emitted when a controller should no longer run because it
+	// was disabled via the --controllers list (an explicit "-name" entry, or
+	// the removal of a "name"/"*" entry that had enabled it).
+	EventStop
+	// EventReconfigure is emitted when a still-enabled controller's
+	// sub-config changed. The handler decides whether it can apply the
+	// change without a restart; a handler that returns an error is reported
+	// by Reload as needing a restart.
+	EventReconfigure
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventStart:
+		return "Start"
+	case EventStop:
+		return "Stop"
+	case EventReconfigure:
+		return "Reconfigure"
+	default:
+		return "Unknown"
+	}
+}
+
+// ConfigChangeEvent is delivered to a controller's registered Handler whenever
+// the ConfigWatcher detects that controller's sub-config changed on disk.
+type ConfigChangeEvent struct {
+	Type EventType
+	// Config is the new sub-controller config struct (e.g. a
+	// *hpaconfig.HPAControllerConfiguration). Nil for EventStop.
+	Config interface{}
+}
+
+// Handler is implemented by sub-controllers that want to react to
+// configuration changes without requiring a kube-controller-manager restart.
+// ApplyConfig receives the new sub-config and returns an error if it cannot
+// be applied in place; the ConfigWatcher treats a non-nil error as "this
+// controller needs a full restart to pick up the change" and reports it to
+// the caller instead of retrying.
+type Handler interface {
+	ApplyConfig(event ConfigChangeEvent) error
+}
+
+// Loader reads a KubeControllerManagerConfiguration document from its
+// backing store; the ConfigWatcher decodes it. Implementations may read from a local file or a
+// ConfigMap; both just need to return the current bytes of the YAML
+// document.
+type Loader interface {
+	Load() ([]byte, error)
+}
+
+// ConfigWatcher polls a Loader for changes to the KubeControllerManagerConfiguration,
+// validates the new document, diffs it against the last-applied configuration,
+// and notifies registered per-controller Handlers of the result. Start and
+// Stop are driven by Generic.Controllers (the --controllers enable/disable
+// list); Reconfigure is driven by a struct diff of a still-enabled
+// controller's own sub-config section.
+type ConfigWatcher struct {
+	loader   Loader
+	validate func(*KubeControllerManagerConfiguration) error
+
+	mu       sync.Mutex
+	current  *KubeControllerManagerConfiguration
+	handlers map[string]Handler
+}
+
+// NewConfigWatcher creates a ConfigWatcher that reads its configuration via
+// loader and rejects documents that fail validate. validate may be nil, in
+// which case only decoding is performed.
+func NewConfigWatcher(loader Loader, validate func(*KubeControllerManagerConfiguration) error) *ConfigWatcher {
+	return &ConfigWatcher{
+		loader:   loader,
+		validate: validate,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// RegisterHandler associates a sub-controller name (e.g. "garbagecollector")
+// with the Handler that should be notified when that controller's section of
+// the configuration changes. Registering a second handler for the same name
+// replaces the first.
+func (w *ConfigWatcher) RegisterHandler(name string, handler Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[name] = handler
+}
+
+// Reload loads the current configuration from the Loader, validates it, and,
+// if this is not the first call, diffs it against the previously applied
+// configuration and fans out ConfigChangeEvents to any registered handlers
+// whose section changed. It returns the names of controllers whose handler
+// refused the change, meaning the caller must fall back to a process restart
+// to apply those sections. Controllers with no registered handler are not
+// notified and are not included in the result.
+func (w *ConfigWatcher) Reload(ctx context.Context) (needsRestart []string, err error) {
+	raw, err := w.loader.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load controller manager configuration: %w", err)
+	}
+
+	next := &KubeControllerManagerConfiguration{}
+	if err := yaml.Unmarshal(raw, next); err != nil {
+		return nil, fmt.Errorf("failed to decode controller manager configuration: %w", err)
+	}
+	if w.validate != nil {
+		if err := w.validate(next); err != nil {
+			return nil, fmt.Errorf("invalid controller manager configuration: %w", err)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	prev := w.current
+	w.current = next.DeepCopy()
+
+	if prev == nil {
+		// Initial load: every registered controller enabled by the
+		// --controllers list gets an EventStart, but there is nothing to
+		// restart since nothing was running yet.
+		for name, handler := range w.handlers {
+			if !controllerEnabled(next.Generic.Controllers, name) {
+				continue
+			}
+			if err := handler.ApplyConfig(ConfigChangeEvent{Type: EventStart, Config: subConfigFor(next, name)}); err != nil {
+				klog.ErrorS(err, "controller rejected initial configuration", "controller", name)
+			}
+		}
+		return nil, nil
+	}
+
+	for name, handler := range w.handlers {
+		prevEnabled := controllerEnabled(prev.Generic.Controllers, name)
+		nextEnabled := controllerEnabled(next.Generic.Controllers, name)
+
+		var event ConfigChangeEvent
+		switch {
+		case !prevEnabled && nextEnabled:
+			event = ConfigChangeEvent{Type: EventStart, Config: subConfigFor(next, name)}
+		case prevEnabled && !nextEnabled:
+			event = ConfigChangeEvent{Type: EventStop}
+		case nextEnabled:
+			prevCfg := subConfigFor(prev, name)
+			nextCfg := subConfigFor(next, name)
+			if reflect.DeepEqual(prevCfg, nextCfg) {
+				continue
+			}
+			event = ConfigChangeEvent{Type: EventReconfigure, Config: nextCfg}
+		default:
+			// stayed disabled across the reload: nothing to do
+			continue
+		}
+
+		if err := handler.ApplyConfig(event); err != nil {
+			klog.ErrorS(err, "controller could not apply configuration change in place, restart required", "controller", name)
+			needsRestart = append(needsRestart, name)
+		}
+	}
+
+	return needsRestart, nil
+}
+
+// controllerEnabled reports whether name is enabled by controllers, which
+// follows the --controllers flag convention: "name" enables it explicitly,
+// "-name" disables it explicitly, and "*" enables every controller not
+// explicitly disabled. A more specific entry for name always wins over "*",
+// regardless of each entry's position in the list.
+func controllerEnabled(controllers []string, name string) bool {
+	star := false
+	for _, c := range controllers {
+		switch c {
+		case name:
+			return true
+		case "-" + name:
+			return false
+		case "*":
+			star = true
+		}
+	}
+	return star
+}
+
+// subConfigFor returns the sub-controller config struct named by name, or nil
+// if cfg is nil or name is not a known controller section. It is deliberately
+// small and explicit rather than reflection-driven over field names, since
+// the mapping between controller name and config struct is part of the
+// kube-controller-manager's public contract.
+func subConfigFor(cfg *KubeControllerManagerConfiguration, name string) interface{} {
+	if cfg == nil {
+		return nil
+	}
+	switch name {
+	case "garbagecollector":
+		return &cfg.GarbageCollectorController
+	case "horizontalpodautoscaling":
+		return &cfg.HPAController
+	case "job":
+		return &cfg.JobController
+	case "daemonset":
+		return &cfg.DaemonSetController
+	case "deployment":
+		return &cfg.DeploymentController
+	case "statefulset":
+		return &cfg.StatefulSetController
+	case "nodeipam":
+		return &cfg.NodeIPAMController
+	case "nodelifecycle":
+		return &cfg.NodeLifecycleController
+	default:
+		return nil
+	}
+}
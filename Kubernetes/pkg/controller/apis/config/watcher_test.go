@@ -0,0 +1,141 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type fakeLoader struct {
+	docs [][]byte
+	next int
+}
+
+func (f *fakeLoader) Load() ([]byte, error) {
+	doc := f.docs[f.next]
+	if f.next < len(f.docs)-1 {
+		f.next++
+	}
+	return doc, nil
+}
+
+type recordingHandler struct {
+	events []ConfigChangeEvent
+	reject bool
+}
+
+func (h *recordingHandler) ApplyConfig(event ConfigChangeEvent) error {
+	h.events = append(h.events, event)
+	if h.reject {
+		return errRejected
+	}
+	return nil
+}
+
+var errRejected = fmt.Errorf("controller cannot apply change in place")
+
+func TestConfigWatcherReload(t *testing.T) {
+	loader := &fakeLoader{docs: [][]byte{
+		[]byte("generic:\n  controllers:\n  - \"*\"\njobController:\n  concurrentJobSyncs: 5\n"),
+		[]byte("generic:\n  controllers:\n  - \"*\"\njobController:\n  concurrentJobSyncs: 10\n"),
+	}}
+	handler := &recordingHandler{}
+
+	w := NewConfigWatcher(loader, nil)
+	w.RegisterHandler("job", handler)
+
+	if _, err := w.Reload(context.Background()); err != nil {
+		t.Fatalf("initial Reload failed: %v", err)
+	}
+	if len(handler.events) != 1 || handler.events[0].Type != EventStart {
+		t.Fatalf("expected a single EventStart, got %+v", handler.events)
+	}
+
+	needsRestart, err := w.Reload(context.Background())
+	if err != nil {
+		t.Fatalf("second Reload failed: %v", err)
+	}
+	if len(needsRestart) != 0 {
+		t.Fatalf("expected no controllers to require a restart, got %v", needsRestart)
+	}
+	if len(handler.events) != 2 || handler.events[1].Type != EventReconfigure {
+		t.Fatalf("expected a single EventReconfigure, got %+v", handler.events)
+	}
+}
+
+func TestConfigWatcherReloadNeedsRestart(t *testing.T) {
+	loader := &fakeLoader{docs: [][]byte{
+		[]byte("generic:\n  controllers:\n  - \"*\"\njobController:\n  concurrentJobSyncs: 5\n"),
+		[]byte("generic:\n  controllers:\n  - \"*\"\njobController:\n  concurrentJobSyncs: 10\n"),
+	}}
+	handler := &recordingHandler{reject: true}
+
+	w := NewConfigWatcher(loader, nil)
+	w.RegisterHandler("job", handler)
+
+	if _, err := w.Reload(context.Background()); err != nil {
+		t.Fatalf("initial Reload failed: %v", err)
+	}
+
+	needsRestart, err := w.Reload(context.Background())
+	if err != nil {
+		t.Fatalf("second Reload failed: %v", err)
+	}
+	if len(needsRestart) != 1 || needsRestart[0] != "job" {
+		t.Fatalf("expected job to require a restart, got %v", needsRestart)
+	}
+}
+
+func TestConfigWatcherReloadStartStop(t *testing.T) {
+	loader := &fakeLoader{docs: [][]byte{
+		[]byte("generic:\n  controllers:\n  - \"*\"\n  - \"-job\"\n"),
+		[]byte("generic:\n  controllers:\n  - \"*\"\njobController:\n  concurrentJobSyncs: 5\n"),
+		[]byte("generic:\n  controllers:\n  - \"*\"\n  - \"-job\"\njobController:\n  concurrentJobSyncs: 5\n"),
+	}}
+	handler := &recordingHandler{}
+
+	w := NewConfigWatcher(loader, nil)
+	w.RegisterHandler("job", handler)
+
+	// job is disabled on the initial load, so it must not start.
+	if _, err := w.Reload(context.Background()); err != nil {
+		t.Fatalf("initial Reload failed: %v", err)
+	}
+	if len(handler.events) != 0 {
+		t.Fatalf("expected no events while job is disabled, got %+v", handler.events)
+	}
+
+	// job becomes enabled: it must start even though its sub-config section
+	// did not previously exist to diff against.
+	if _, err := w.Reload(context.Background()); err != nil {
+		t.Fatalf("second Reload failed: %v", err)
+	}
+	if len(handler.events) != 1 || handler.events[0].Type != EventStart {
+		t.Fatalf("expected a single EventStart, got %+v", handler.events)
+	}
+
+	// job becomes disabled again, despite its sub-config section being
+	// unchanged: it must stop.
+	if _, err := w.Reload(context.Background()); err != nil {
+		t.Fatalf("third Reload failed: %v", err)
+	}
+	if len(handler.events) != 2 || handler.events[1].Type != EventStop {
+		t.Fatalf("expected a single EventStop, got %+v", handler.events)
+	}
+}
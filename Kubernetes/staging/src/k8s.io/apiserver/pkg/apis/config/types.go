@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config contains the internal representation of the apiserver
+// EncryptionConfiguration API, loaded from --encryption-provider-config.
+package config
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EncryptionConfiguration stores the complete configuration for encryption
+// providers. Resources are matched against ResourceConfiguration.Resources in
+// list order; the first entry whose Resources contains the requested
+// resource wins, so more specific entries must be listed before wildcards
+// that would otherwise shadow them.
+type EncryptionConfiguration struct {
+	metav1.TypeMeta
+
+	// Resources is the list of per-resource configurations, evaluated in
+	// order.
+	Resources []ResourceConfiguration
+}
+
+// ResourceConfiguration configures encryption of a list of resources for a
+// list of providers, tried in order until one succeeds.
+type ResourceConfiguration struct {
+	// Resources is a list of resource specifications. Each entry is either
+	// an explicit "<resource>.<group>" (or bare "<resource>" for the legacy
+	// core group), or a wildcard: "*.<group>" matches every resource in
+	// group, and "*.*" matches every resource in every group.
+	Resources []string
+
+	// Providers is the list of transformers for the given resources, tried
+	// in order. The first provider is used to encrypt; all are tried, in
+	// order, to decrypt.
+	Providers []ProviderConfiguration
+}
+
+// ProviderConfiguration stores the provided configuration for an encryption
+// provider. Exactly one field must be set, or exactly one entry in
+// Extensions for a provider kind registered via RegisterProvider.
+type ProviderConfiguration struct {
+	AESGCM    *AESConfiguration
+	AESCBC    *AESConfiguration
+	Secretbox *SecretboxConfiguration
+	Identity  *IdentityConfiguration
+	KMS       *KMSConfiguration
+
+	// Extensions holds the raw, not-yet-parsed configuration for any
+	// provider kind other than the built-in ones above, keyed by its kind
+	// name (the YAML key under "providers", e.g. "xor"). It is populated by
+	// v1.ProviderConfiguration's UnmarshalJSON and handed to the
+	// ProviderFactory registered under that name by buildPrefixTransformer.
+	Extensions map[string][]byte
+}
+
+// AESConfiguration contains the API configuration for an AES transformer.
+type AESConfiguration struct {
+	Keys []Key
+}
+
+// SecretboxConfiguration contains the API configuration for a Secretbox
+// transformer.
+type SecretboxConfiguration struct {
+	Keys []Key
+}
+
+// Key contains name and secret of the provided key for a transformer.
+type Key struct {
+	// Name is the name of the key to be used while storing data to disk.
+	Name string
+	// Secret is the actual key, encoded in base64.
+	Secret string
+}
+
+// IdentityConfiguration is an empty struct to allow identity transformer in
+// provider configuration.
+type IdentityConfiguration struct{}
+
+// KMSConfiguration contains the API configuration for a KMS transformer.
+type KMSConfiguration struct {
+	// APIVersion of the KMS plugin, "v1" or "v2".
+	APIVersion string
+	// Name is the name of the KMS plugin.
+	Name string
+	// Endpoint is the gRPC server listening address, for example a unix
+	// socket "unix:///var/run/kms-provider.sock".
+	Endpoint string
+	// CacheSize is the maximum number of secrets that are cached in memory.
+	// Only applies to APIVersion v1. It is not defaulted when unset.
+	CacheSize *int32
+	// Timeout for gRPC calls made to the KMS plugin.
+	Timeout metav1.Duration
+	// MaxWritesPerDEK optionally bounds how many objects may be encrypted
+	// under a single DEK (or, with KMSv2KDF, KDF seed) before it is
+	// proactively replaced even if the KMS plugin's KeyID hasn't changed.
+	// Unset or zero means no limit. Only applies to APIVersion v2.
+	MaxWritesPerDEK *int64
+	// MaxDEKAge optionally bounds how long a single DEK may be used before
+	// it is proactively replaced even if the KMS plugin's KeyID hasn't
+	// changed. Unset or zero means no limit. Only applies to APIVersion v2.
+	MaxDEKAge *metav1.Duration
+	// Algorithm selects the AEAD cipher used to seal values under this
+	// provider's DEK: "AES-GCM" (the default, if unset), "AES-GCM-SIV", or
+	// "ChaCha20-Poly1305". Only applies to APIVersion v2.
+	Algorithm string
+}
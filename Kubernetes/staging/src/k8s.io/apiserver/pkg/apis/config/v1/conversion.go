@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"k8s.io/apiserver/pkg/apis/config"
+)
+
+// Convert_v1_EncryptionConfiguration_To_config_EncryptionConfiguration
+// converts a versioned EncryptionConfiguration, as parsed from
+// --encryption-provider-config, to the internal type the apiserver builds
+// its transformer chains from.
+func Convert_v1_EncryptionConfiguration_To_config_EncryptionConfiguration(in *EncryptionConfiguration, out *config.EncryptionConfiguration) {
+	out.TypeMeta = in.TypeMeta
+	out.Resources = make([]config.ResourceConfiguration, len(in.Resources))
+	for i := range in.Resources {
+		Convert_v1_ResourceConfiguration_To_config_ResourceConfiguration(&in.Resources[i], &out.Resources[i])
+	}
+}
+
+// Convert_v1_ResourceConfiguration_To_config_ResourceConfiguration converts a
+// single versioned ResourceConfiguration entry to internal.
+func Convert_v1_ResourceConfiguration_To_config_ResourceConfiguration(in *ResourceConfiguration, out *config.ResourceConfiguration) {
+	out.Resources = append([]string(nil), in.Resources...)
+	out.Providers = make([]config.ProviderConfiguration, len(in.Providers))
+	for i := range in.Providers {
+		Convert_v1_ProviderConfiguration_To_config_ProviderConfiguration(&in.Providers[i], &out.Providers[i])
+	}
+}
+
+// Convert_v1_ProviderConfiguration_To_config_ProviderConfiguration converts a
+// single versioned ProviderConfiguration to internal. Exactly one field of in
+// is expected to be set; out mirrors whichever it is.
+func Convert_v1_ProviderConfiguration_To_config_ProviderConfiguration(in *ProviderConfiguration, out *config.ProviderConfiguration) {
+	if in.AESGCM != nil {
+		keys := append([]config.Key(nil), convertKeys(in.AESGCM.Keys)...)
+		out.AESGCM = &config.AESConfiguration{Keys: keys}
+	}
+	if in.AESCBC != nil {
+		keys := append([]config.Key(nil), convertKeys(in.AESCBC.Keys)...)
+		out.AESCBC = &config.AESConfiguration{Keys: keys}
+	}
+	if in.Secretbox != nil {
+		keys := append([]config.Key(nil), convertKeys(in.Secretbox.Keys)...)
+		out.Secretbox = &config.SecretboxConfiguration{Keys: keys}
+	}
+	if in.Identity != nil {
+		out.Identity = &config.IdentityConfiguration{}
+	}
+	if in.KMS != nil {
+		out.KMS = &config.KMSConfiguration{
+			APIVersion:      in.KMS.APIVersion,
+			Name:            in.KMS.Name,
+			Endpoint:        in.KMS.Endpoint,
+			CacheSize:       in.KMS.CacheSize,
+			Timeout:         in.KMS.Timeout,
+			MaxWritesPerDEK: in.KMS.MaxWritesPerDEK,
+			MaxDEKAge:       in.KMS.MaxDEKAge,
+			Algorithm:       in.KMS.Algorithm,
+		}
+	}
+	if len(in.Extensions) > 0 {
+		out.Extensions = make(map[string][]byte, len(in.Extensions))
+		for kind, raw := range in.Extensions {
+			out.Extensions[kind] = append([]byte(nil), raw...)
+		}
+	}
+}
+
+func convertKeys(in []Key) []config.Key {
+	out := make([]config.Key, len(in))
+	for i := range in {
+		out[i] = config.Key{Name: in[i].Name, Secret: in[i].Secret}
+	}
+	return out
+}
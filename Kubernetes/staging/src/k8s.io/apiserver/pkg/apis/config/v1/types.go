@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +k8s:deepcopy-gen=package
+// +groupName=apiserver.config.k8s.io
+
+// Package v1 is the v1 version of the apiserver EncryptionConfiguration API.
+package v1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EncryptionConfiguration stores the complete configuration for encryption
+// providers, as read from --encryption-provider-config.
+type EncryptionConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Resources is the list of per-resource configurations, evaluated in
+	// order; the first entry whose Resources contains the requested
+	// resource wins.
+	Resources []ResourceConfiguration `json:"resources"`
+}
+
+// ResourceConfiguration configures encryption of a list of resources for a
+// list of providers.
+type ResourceConfiguration struct {
+	// Resources is a list of resource specifications, for example
+	// "secrets", "events.events.k8s.io", "*.apps" or "*.*".
+	Resources []string `json:"resources"`
+
+	// Providers is the list of transformers for the given resources, tried
+	// in order.
+	Providers []ProviderConfiguration `json:"providers"`
+}
+
+// ProviderConfiguration stores the provided configuration for an encryption
+// provider. Besides the built-in kinds below, it also accepts any kind name
+// registered via RegisterProvider, captured in Extensions.
+type ProviderConfiguration struct {
+	AESGCM    *AESConfiguration       `json:"aesgcm,omitempty"`
+	AESCBC    *AESConfiguration       `json:"aescbc,omitempty"`
+	Secretbox *SecretboxConfiguration `json:"secretbox,omitempty"`
+	Identity  *IdentityConfiguration  `json:"identity,omitempty"`
+	KMS       *KMSConfiguration       `json:"kms,omitempty"`
+
+	// Extensions holds the raw configuration for any provider kind other
+	// than the built-in ones above, keyed by its kind name. Populated by
+	// UnmarshalJSON, since the built-in kinds are exhaustively listed by
+	// struct tag and there is no static Go type for an out-of-tree kind.
+	Extensions map[string]json.RawMessage `json:"-"`
+}
+
+// knownProviderKinds are the JSON keys ProviderConfiguration's built-in
+// fields already claim; UnmarshalJSON treats every other top-level key as an
+// extension provider kind.
+var knownProviderKinds = map[string]bool{
+	"aesgcm":    true,
+	"aescbc":    true,
+	"secretbox": true,
+	"identity":  true,
+	"kms":       true,
+}
+
+// UnmarshalJSON decodes the built-in provider kinds normally and stashes any
+// other top-level key as an Extensions entry, so a provider kind registered
+// via RegisterProvider round-trips through YAML parsing without this
+// package needing to know its shape.
+func (p *ProviderConfiguration) UnmarshalJSON(data []byte) error {
+	type plain ProviderConfiguration
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for kind := range knownProviderKinds {
+		delete(raw, kind)
+	}
+	if len(raw) > 0 {
+		p.Extensions = raw
+	}
+	return nil
+}
+
+// AESConfiguration contains the API configuration for an AES transformer.
+type AESConfiguration struct {
+	Keys []Key `json:"keys"`
+}
+
+// SecretboxConfiguration contains the API configuration for a Secretbox
+// transformer.
+type SecretboxConfiguration struct {
+	Keys []Key `json:"keys"`
+}
+
+// Key contains name and secret of the provided key for a transformer.
+type Key struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+}
+
+// IdentityConfiguration is an empty struct to allow identity transformer in
+// provider configuration.
+type IdentityConfiguration struct{}
+
+// KMSConfiguration contains the API configuration for a KMS transformer.
+type KMSConfiguration struct {
+	APIVersion string          `json:"apiVersion"`
+	Name       string          `json:"name"`
+	Endpoint   string          `json:"endpoint"`
+	CacheSize  *int32          `json:"cachesize,omitempty"`
+	Timeout    metav1.Duration `json:"timeout,omitempty"`
+	// MaxWritesPerDEK optionally bounds how many objects may be encrypted
+	// under a single DEK before it is proactively rotated even if the KMS
+	// plugin's key ID hasn't changed. Only applies to APIVersion v2.
+	MaxWritesPerDEK *int64 `json:"maxWritesPerDEK,omitempty"`
+	// MaxDEKAge optionally bounds how long a single DEK may be used before
+	// it is proactively rotated even if the KMS plugin's key ID hasn't
+	// changed. Only applies to APIVersion v2.
+	MaxDEKAge *metav1.Duration `json:"maxDEKAge,omitempty"`
+	// Algorithm selects the AEAD cipher used to seal values under this
+	// provider's DEK: "AES-GCM" (the default, if unset), "AES-GCM-SIV", or
+	// "ChaCha20-Poly1305". Only applies to APIVersion v2.
+	Algorithm string `json:"algorithm,omitempty"`
+}
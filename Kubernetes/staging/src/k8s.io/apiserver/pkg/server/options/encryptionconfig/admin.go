@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// kmsv2Registry lets the /kms-providers/rotate and /kms-providers/stats
+// admin endpoints reach the live cachingKMSv2State for each configured KMSv2
+// provider, keyed by provider name. It is rebuilt on every successful
+// reload, so it always reflects the currently active EncryptionConfiguration.
+type kmsv2Registry struct {
+	mu     sync.RWMutex
+	byName map[string]*cachingKMSv2State
+}
+
+func newKMSv2Registry() *kmsv2Registry {
+	return &kmsv2Registry{byName: make(map[string]*cachingKMSv2State)}
+}
+
+func (r *kmsv2Registry) register(name string, s *cachingKMSv2State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byName[name] = s
+}
+
+func (r *kmsv2Registry) get(name string) (*cachingKMSv2State, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.byName[name]
+	return s, ok
+}
+
+// replaceFrom atomically swaps r's contents for other's, so a reload that
+// builds its replacement providers into a throwaway registry can publish
+// them in one step only once they have passed health checking, without ever
+// exposing a partially populated registry to the admin endpoints.
+func (r *kmsv2Registry) replaceFrom(other *kmsv2Registry) {
+	other.mu.RLock()
+	byName := make(map[string]*cachingKMSv2State, len(other.byName))
+	for name, s := range other.byName {
+		byName[name] = s
+	}
+	other.mu.RUnlock()
+
+	r.mu.Lock()
+	r.byName = byName
+	r.mu.Unlock()
+}
+
+func (r *kmsv2Registry) all() []*cachingKMSv2State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]*cachingKMSv2State, 0, len(names))
+	for _, name := range names {
+		out = append(out, r.byName[name])
+	}
+	return out
+}
+
+// KMSv2AdminHandler returns an http.Handler serving the admin endpoints used
+// to operate KMSv2 providers out of band from the normal reload cycle:
+//
+//   - POST /kms-providers/rotate?provider=<name> forces an immediate Status
+//     check against the named provider and, if its key ID changed,
+//     invalidates the cached DEK so the very next write wraps a fresh one.
+//   - GET /kms-providers/stats[?provider=<name>] reports, for the named
+//     provider (or every configured KMSv2 provider, if provider is omitted),
+//     the current key ID, the number of DEK generations still tracked, the
+//     age of the oldest one, and the number of writes served by each.
+func (d *DynamicEncryptionConfiguration) KMSv2AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/kms-providers/rotate", d.handleKMSv2Rotate)
+	mux.HandleFunc("/kms-providers/stats", d.handleKMSv2Stats)
+	return mux
+}
+
+func (d *DynamicEncryptionConfiguration) handleKMSv2Rotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("provider")
+	if len(name) == 0 {
+		http.Error(w, "missing required \"provider\" query parameter", http.StatusBadRequest)
+		return
+	}
+	state, ok := d.kmsv2Registry.get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no KMSv2 provider named %q is configured", name), http.StatusNotFound)
+		return
+	}
+
+	rotated, err := state.rotate(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Rotated bool `json:"rotated"`
+	}{Rotated: rotated})
+}
+
+func (d *DynamicEncryptionConfiguration) handleKMSv2Stats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var states []*cachingKMSv2State
+	if name := r.URL.Query().Get("provider"); len(name) > 0 {
+		state, ok := d.kmsv2Registry.get(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("no KMSv2 provider named %q is configured", name), http.StatusNotFound)
+			return
+		}
+		states = []*cachingKMSv2State{state}
+	} else {
+		states = d.kmsv2Registry.all()
+	}
+
+	stats := make([]kmsv2ProviderStats, 0, len(states))
+	for _, state := range states {
+		stats = append(stats, state.stats())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
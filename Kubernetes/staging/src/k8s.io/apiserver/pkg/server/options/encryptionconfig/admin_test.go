@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/storage/value"
+	kmsv2svc "k8s.io/kms/pkg/service"
+)
+
+func TestKMSv2AdminHandlerRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "encryption-config.yaml")
+	writeConfig(t, path, `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - kms:
+        apiVersion: v2
+        name: provider-a
+        endpoint: unix:///provider-a.sock
+`)
+
+	oldFactory := EnvelopeKMSv2ServiceFactory
+	defer func() { EnvelopeKMSv2ServiceFactory = oldFactory }()
+	fake := &fakeKMSv2Service{keyID: "key-1"}
+	EnvelopeKMSv2ServiceFactory = func(ctx context.Context, endpoint, providerName string, callTimeout time.Duration) (kmsv2svc.Service, error) {
+		return fake, nil
+	}
+
+	d, err := NewDynamicEncryptionConfiguration(path)
+	if err != nil {
+		t.Fatalf("NewDynamicEncryptionConfiguration failed: %v", err)
+	}
+	handler := d.KMSv2AdminHandler()
+
+	// write once so a DEK wrapped under key-1 is cached.
+	secrets, ok := d.TransformerForResource(schema.GroupResource{Resource: "secrets"})
+	if !ok {
+		t.Fatalf("expected a transformer for secrets")
+	}
+	if _, err := secrets.TransformToStorage(context.Background(), []byte("hello"), value.DefaultContext("ctx")); err != nil {
+		t.Fatalf("TransformToStorage failed: %v", err)
+	}
+
+	// rotating before the plugin's key ID has changed should be a no-op.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/kms-providers/rotate?provider=provider-a", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("rotate: got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if body := rr.Body.String(); body != "{\"rotated\":false}\n" {
+		t.Fatalf("rotate with unchanged key ID: got body %q", body)
+	}
+
+	fake.keyID = "key-2"
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/kms-providers/rotate?provider=provider-a", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("rotate: got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if body := rr.Body.String(); body != "{\"rotated\":true}\n" {
+		t.Fatalf("rotate with changed key ID: got body %q", body)
+	}
+
+	// rotating an unconfigured provider must 404, not panic.
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/kms-providers/rotate?provider=does-not-exist", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("rotate unknown provider: got status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestKMSv2AdminHandlerStats(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "encryption-config.yaml")
+	writeConfig(t, path, `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - kms:
+        apiVersion: v2
+        name: provider-a
+        endpoint: unix:///provider-a.sock
+`)
+
+	oldFactory := EnvelopeKMSv2ServiceFactory
+	defer func() { EnvelopeKMSv2ServiceFactory = oldFactory }()
+	fake := &fakeKMSv2Service{keyID: "key-1"}
+	EnvelopeKMSv2ServiceFactory = func(ctx context.Context, endpoint, providerName string, callTimeout time.Duration) (kmsv2svc.Service, error) {
+		return fake, nil
+	}
+
+	d, err := NewDynamicEncryptionConfiguration(path)
+	if err != nil {
+		t.Fatalf("NewDynamicEncryptionConfiguration failed: %v", err)
+	}
+	handler := d.KMSv2AdminHandler()
+
+	secrets, ok := d.TransformerForResource(schema.GroupResource{Resource: "secrets"})
+	if !ok {
+		t.Fatalf("expected a transformer for secrets")
+	}
+	dataCtx := value.DefaultContext("ctx")
+	for i := 0; i < 3; i++ {
+		if _, err := secrets.TransformToStorage(context.Background(), []byte("hello"), dataCtx); err != nil {
+			t.Fatalf("TransformToStorage failed: %v", err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/kms-providers/stats?provider=provider-a", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("stats: got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if body := rr.Body.String(); body == "" {
+		t.Fatalf("stats: expected a non-empty response body")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/kms-providers/stats?provider=does-not-exist", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("stats unknown provider: got status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package encryptionconfig parses --encryption-provider-config and builds
+// the resource-to-transformer routing the apiserver uses for encryption at
+// rest.
+package encryptionconfig
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apiserver/pkg/apis/config"
+	apiserverconfigv1 "k8s.io/apiserver/pkg/apis/config/v1"
+)
+
+// LoadEncryptionConfig parses an EncryptionConfiguration document and
+// validates that its Resources entries do not contain ambiguous or
+// unreachable wildcards. See validateResourceConfigurations for the exact
+// rules.
+func LoadEncryptionConfig(data []byte) (*config.EncryptionConfiguration, error) {
+	versioned := &apiserverconfigv1.EncryptionConfiguration{}
+	if err := yaml.Unmarshal(data, versioned); err != nil {
+		return nil, fmt.Errorf("failed to parse EncryptionConfiguration: %w", err)
+	}
+
+	internal := &config.EncryptionConfiguration{}
+	apiserverconfigv1.Convert_v1_EncryptionConfiguration_To_config_EncryptionConfiguration(versioned, internal)
+
+	if err := validateResourceConfigurations(internal.Resources); err != nil {
+		return nil, fmt.Errorf("invalid EncryptionConfiguration: %w", err)
+	}
+	return internal, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/apis/config"
+)
+
+// resourceSpec is a single parsed entry from ResourceConfiguration.Resources,
+// for example "secrets", "events.events.k8s.io", "*.apps" or "*.*".
+type resourceSpec struct {
+	raw      string
+	group    string
+	resource string
+}
+
+// parseResourceSpec splits raw on its first "." into resource and group,
+// matching the same "<resource>.<group>" convention used throughout the rest
+// of the API machinery (e.g. kubectl's resource.group shorthand). A spec
+// with no "." is an explicit resource in the legacy core group. "*" in the
+// resource position means "every resource"; "*" in the group position means
+// "every group" only alongside a "*" resource. "<resource>.*" is not
+// rejected: it parses as an explicit resource in a group literally named
+// "*", which no real resource matches.
+func parseResourceSpec(raw string) resourceSpec {
+	if idx := strings.Index(raw, "."); idx != -1 {
+		return resourceSpec{raw: raw, resource: raw[:idx], group: raw[idx+1:]}
+	}
+	return resourceSpec{raw: raw, resource: raw}
+}
+
+func (s resourceSpec) isGlobalWildcard() bool { return s.resource == "*" && s.group == "*" }
+func (s resourceSpec) isGroupWildcard() bool  { return s.resource == "*" && s.group != "*" }
+
+// matches reports whether spec covers gr, including any applicable wildcard.
+func (s resourceSpec) matches(gr schema.GroupResource) bool {
+	switch {
+	case s.isGlobalWildcard():
+		return true
+	case s.isGroupWildcard():
+		return gr.Group == s.group
+	default:
+		return gr.Group == s.group && gr.Resource == s.resource
+	}
+}
+
+// ResourceConfigurationForGroupResource returns the first ResourceConfiguration
+// in resourceConfigs whose Resources matches gr, in list order. This is used
+// both for statically known built-in resources and for CRDs registered after
+// the apiserver starts, since wildcard entries need no update when a new CRD
+// is added.
+func ResourceConfigurationForGroupResource(resourceConfigs []config.ResourceConfiguration, gr schema.GroupResource) (*config.ResourceConfiguration, bool) {
+	for i := range resourceConfigs {
+		for _, raw := range resourceConfigs[i].Resources {
+			if parseResourceSpec(raw).matches(gr) {
+				return &resourceConfigs[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// validateResourceConfigurations rejects two classes of wildcard misuse:
+//
+//   - Overlap within a single ResourceConfiguration's Resources list, e.g.
+//     listing both "*.apps" and "deployments.apps" (or "*.*" and anything
+//     else) in the same entry, which is always redundant.
+//   - A wildcard in an earlier entry that shadows a more specific entry
+//     listed later, which would silently make the later entry unreachable
+//     since the first match wins. Users must list specific overrides (e.g.
+//     "events" routed to the identity provider) before a catch-all like
+//     "*.*" routed to kms.
+func validateResourceConfigurations(resourceConfigs []config.ResourceConfiguration) error {
+	var sawGlobalWildcard bool
+	sawGroupWildcards := sets.NewString()
+	sawExact := sets.NewString()
+
+	for _, rc := range resourceConfigs {
+		var localGlobalWildcard bool
+		localGroupWildcards := sets.NewString()
+		localExact := sets.NewString()
+
+		for _, raw := range rc.Resources {
+			spec := parseResourceSpec(raw)
+
+			if sawGlobalWildcard {
+				return fmt.Errorf("resource %q is shadowed by an earlier \"*.*\" wildcard entry and can never be matched", raw)
+			}
+
+			switch {
+			case spec.isGlobalWildcard():
+				if localGlobalWildcard || len(localGroupWildcards) > 0 || localExact.Len() > 0 {
+					return fmt.Errorf("resource %q overlaps with another entry already listed in the same resource list", raw)
+				}
+				localGlobalWildcard = true
+
+			case spec.isGroupWildcard():
+				if sawGroupWildcards.Has(spec.group) {
+					return fmt.Errorf("resource %q is shadowed by an earlier \"*.%s\" wildcard entry and can never be matched", raw, spec.group)
+				}
+				if localGlobalWildcard || localGroupWildcards.Has(spec.group) {
+					return fmt.Errorf("resource %q overlaps with another entry already listed in the same resource list", raw)
+				}
+				for key := range localExact {
+					if strings.HasPrefix(key, spec.group+"/") {
+						return fmt.Errorf("resource %q overlaps with another entry already listed in the same resource list", raw)
+					}
+				}
+				localGroupWildcards.Insert(spec.group)
+
+			default:
+				key := spec.group + "/" + spec.resource
+				if sawGroupWildcards.Has(spec.group) {
+					return fmt.Errorf("resource %q is shadowed by an earlier \"*.%s\" wildcard entry and can never be matched", raw, spec.group)
+				}
+				if sawExact.Has(key) {
+					return fmt.Errorf("resource %q duplicates an earlier entry for the same resource", raw)
+				}
+				if localGlobalWildcard || localGroupWildcards.Has(spec.group) || localExact.Has(key) {
+					return fmt.Errorf("resource %q overlaps with another entry already listed in the same resource list", raw)
+				}
+				localExact.Insert(key)
+			}
+		}
+
+		if localGlobalWildcard {
+			sawGlobalWildcard = true
+		}
+		sawGroupWildcards = sawGroupWildcards.Union(localGroupWildcards)
+		sawExact = sawExact.Union(localExact)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/apis/config"
+)
+
+func resourceConfig(resources ...string) config.ResourceConfiguration {
+	return config.ResourceConfiguration{Resources: resources}
+}
+
+func TestResourceConfigurationForGroupResource(t *testing.T) {
+	configs := []config.ResourceConfiguration{
+		resourceConfig("events"),
+		resourceConfig("secrets", "configmaps"),
+		resourceConfig("*.apps"),
+		resourceConfig("*.*"),
+	}
+
+	tests := []struct {
+		name    string
+		gr      schema.GroupResource
+		wantIdx int
+		wantOk  bool
+	}{
+		{name: "explicit core resource", gr: schema.GroupResource{Resource: "events"}, wantIdx: 0, wantOk: true},
+		{name: "explicit in named group", gr: schema.GroupResource{Group: "apps", Resource: "deployments"}, wantIdx: 2, wantOk: true},
+		{name: "falls through to global wildcard", gr: schema.GroupResource{Group: "batch", Resource: "jobs"}, wantIdx: 3, wantOk: true},
+		{name: "CRD matched only by global wildcard", gr: schema.GroupResource{Group: "example.com", Resource: "widgets"}, wantIdx: 3, wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ResourceConfigurationForGroupResource(configs, tt.gr)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok=%v, want %v", ok, tt.wantOk)
+			}
+			if ok && got != &configs[tt.wantIdx] {
+				t.Fatalf("matched entry index %v, want %v", got, &configs[tt.wantIdx])
+			}
+		})
+	}
+}
+
+func TestValidateResourceConfigurations(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []config.ResourceConfiguration
+		wantErr bool
+	}{
+		{
+			name:    "explicit override before catch-all is valid",
+			configs: []config.ResourceConfiguration{resourceConfig("events"), resourceConfig("*.*")},
+			wantErr: false,
+		},
+		{
+			name:    "group wildcard before global wildcard is valid",
+			configs: []config.ResourceConfiguration{resourceConfig("*.apps"), resourceConfig("*.*")},
+			wantErr: false,
+		},
+		{
+			name:    "global wildcard and group wildcard in the same entry overlap",
+			configs: []config.ResourceConfiguration{resourceConfig("*.*", "*.apps")},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate group wildcard in the same entry overlaps",
+			configs: []config.ResourceConfiguration{resourceConfig("*.apps", "*.apps")},
+			wantErr: true,
+		},
+		{
+			name:    "explicit resource already covered by a wildcard in the same entry overlaps",
+			configs: []config.ResourceConfiguration{resourceConfig("*.apps", "deployments.apps")},
+			wantErr: true,
+		},
+		{
+			name:    "explicit entry after a matching group wildcard is unreachable",
+			configs: []config.ResourceConfiguration{resourceConfig("*.apps"), resourceConfig("deployments.apps")},
+			wantErr: true,
+		},
+		{
+			name:    "any entry after a global wildcard is unreachable",
+			configs: []config.ResourceConfiguration{resourceConfig("*.*"), resourceConfig("events")},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate explicit entry across separate entries is rejected",
+			configs: []config.ResourceConfiguration{resourceConfig("events"), resourceConfig("events")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceConfigurations(tt.configs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err=%v, wantErr=%v", err, tt.wantErr)
+			}
+		})
+	}
+}
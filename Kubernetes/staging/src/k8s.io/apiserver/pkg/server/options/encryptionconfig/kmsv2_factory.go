@@ -0,0 +1,40 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+	"time"
+
+	kmsv2svc "k8s.io/kms/pkg/service"
+)
+
+// KMSv2ServiceFactory dials the KMSv2 plugin listening at endpoint and
+// returns a client for it. Tests override EnvelopeKMSv2ServiceFactory with a
+// fake so the rest of the apiserver's wiring can be exercised without a real
+// KMS plugin process.
+type KMSv2ServiceFactory func(ctx context.Context, endpoint, providerName string, callTimeout time.Duration) (kmsv2svc.Service, error)
+
+// EnvelopeKMSv2ServiceFactory is the hook integration tests replace to inject
+// a mock KMSv2 plugin client in place of dialing a real one.
+var EnvelopeKMSv2ServiceFactory KMSv2ServiceFactory = newGRPCService
+
+// newGRPCService dials the KMSv2 plugin's gRPC endpoint. It is the default,
+// production EnvelopeKMSv2ServiceFactory.
+func newGRPCService(ctx context.Context, endpoint, providerName string, callTimeout time.Duration) (kmsv2svc.Service, error) {
+	return kmsv2svc.NewGRPCService(ctx, endpoint, callTimeout)
+}
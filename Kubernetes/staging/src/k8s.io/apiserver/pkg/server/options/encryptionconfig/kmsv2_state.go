@@ -0,0 +1,303 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2"
+	kmsv2metrics "k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2/metrics"
+	kmsv2svc "k8s.io/kms/pkg/service"
+)
+
+// dekSeedLength is the length, in bytes, of the local DEK (or, with
+// KMSv2KDF enabled, the KDF seed) generated for the plugin to wrap.
+const dekSeedLength = 32
+
+// dekGenerationHistoryLimit bounds how many retired DEK generations a
+// cachingKMSv2State keeps around for the /kms-providers/stats admin
+// endpoint. Older generations are dropped; this is purely observability
+// state and has no bearing on which DEKs can still decrypt existing data.
+const dekGenerationHistoryLimit = 10
+
+// statusCacheTTL bounds how long get reuses the KMS plugin's last known key
+// ID before polling Status again. Without this, every write would pay for a
+// Status round trip to the plugin. A key rotation is picked up at
+// most statusCacheTTL late, which is acceptable since rotate already exists
+// for callers that need it to take effect immediately.
+const statusCacheTTL = time.Second
+
+// newCachingKMSv2StateFunc returns a kmsv2.StateFunc that wraps a fresh,
+// locally generated DEK/seed through svc whenever svc's key ID changes, and
+// otherwise returns the cached wrapped value so repeated writes don't each
+// pay for a round trip to the plugin. If registry is non-nil, the returned
+// state is also registered under providerName so the /kms-providers/rotate
+// and /kms-providers/stats admin endpoints can reach it. apiVersion is
+// compared against the plugin's reported Status().Version by healthCheck.
+// maxWritesPerDEK and maxDEKAge, if positive, proactively roll the DEK even
+// when svc's key ID hasn't changed.
+func newCachingKMSv2StateFunc(svc kmsv2svc.Service, providerName, apiVersion string, maxWritesPerDEK int64, maxDEKAge time.Duration, registry *kmsv2Registry) kmsv2.StateFunc {
+	c := &cachingKMSv2State{
+		svc:             svc,
+		providerName:    providerName,
+		apiVersion:      apiVersion,
+		maxWritesPerDEK: maxWritesPerDEK,
+		maxDEKAge:       maxDEKAge,
+	}
+	if registry != nil {
+		registry.register(providerName, c)
+	}
+	return c.get
+}
+
+// dekGeneration tracks the observability data for a single DEK/seed the
+// plugin has wrapped: which KMS key wrapped it, when it was created, and
+// how many objects have been encrypted under it so far.
+type dekGeneration struct {
+	keyID     string
+	createdAt time.Time
+	writes    int64
+}
+
+// cachingKMSv2State is a kmsv2.StateFunc implementation that caches the
+// wrapped DEK/seed between calls and supports forced, out-of-band rotation
+// via rotate.
+type cachingKMSv2State struct {
+	svc             kmsv2svc.Service
+	providerName    string
+	apiVersion      string
+	maxWritesPerDEK int64
+	maxDEKAge       time.Duration
+
+	mu      sync.Mutex
+	state   kmsv2.State
+	current dekGeneration
+	history []dekGeneration
+
+	// statusMu guards the cached Status response below. It is deliberately
+	// separate from mu: polling Status is a KMS RPC, and it must never be
+	// made while holding mu, or every write would serialize behind it.
+	statusMu       sync.Mutex
+	statusKeyID    string
+	statusErr      error
+	statusPolledAt time.Time
+}
+
+func (c *cachingKMSv2State) get(ctx context.Context) (kmsv2.State, error) {
+	keyID, err := c.pollStatus(ctx)
+	if err != nil {
+		return kmsv2.State{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state.KeyID == keyID && len(c.state.EncryptedDEK) > 0 && !c.dueForProactiveRotationLocked() {
+		kmsv2metrics.RecordDEKCacheHit()
+		c.current.writes++
+		return c.state, nil
+	}
+	kmsv2metrics.RecordDEKCacheMiss()
+
+	seed := make([]byte, dekSeedLength)
+	if _, err := rand.Read(seed); err != nil {
+		return kmsv2.State{}, fmt.Errorf("failed to generate DEK: %w", err)
+	}
+	resp, err := c.svc.Encrypt(ctx, string(uuid.NewUUID()), seed)
+	if err != nil {
+		kmsv2metrics.RecordKMSOperation("encrypt", "error")
+		return kmsv2.State{}, fmt.Errorf("failed to wrap DEK with KMSv2 plugin: %w", err)
+	}
+	kmsv2metrics.RecordKMSOperation("encrypt", "success")
+
+	c.rotateToLocked(resp.KeyId, resp.Ciphertext, resp.Annotations)
+	return c.state, nil
+}
+
+// pollStatus returns the KMS plugin's current key ID, reusing the last
+// Status response for up to statusCacheTTL so get's hot path (a cache hit)
+// does not pay for a plugin round trip on every write. It never holds mu,
+// since a Status poll says nothing about the DEK state mu protects, and two
+// callers racing past a stale cache just issue one redundant Status call
+// each rather than blocking on one another.
+func (c *cachingKMSv2State) pollStatus(ctx context.Context) (string, error) {
+	c.statusMu.Lock()
+	if time.Since(c.statusPolledAt) < statusCacheTTL {
+		keyID, err := c.statusKeyID, c.statusErr
+		c.statusMu.Unlock()
+		return keyID, err
+	}
+	c.statusMu.Unlock()
+
+	status, err := c.svc.Status(ctx)
+
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	c.statusPolledAt = time.Now()
+	if err != nil {
+		kmsv2metrics.RecordKMSOperation("status", "error")
+		c.statusKeyID, c.statusErr = "", fmt.Errorf("failed to get KMSv2 plugin status: %w", err)
+		return "", c.statusErr
+	}
+	kmsv2metrics.RecordKMSOperation("status", "success")
+	if len(status.KeyId) == 0 {
+		kmsv2metrics.RecordInvalidKeyIDFromStatus()
+	} else {
+		kmsv2metrics.RecordKeyIDHash(status.KeyId)
+	}
+	c.statusKeyID, c.statusErr = status.KeyId, nil
+	return c.statusKeyID, nil
+}
+
+// healthCheck calls Status on the underlying KMSv2 plugin and requires a
+// healthy, version-matching, non-empty key ID before a newly loaded
+// EncryptionConfiguration is allowed to replace the transformers currently
+// serving traffic. It does not take c.mu, since it never touches cached DEK
+// state, and is expected to run against a cachingKMSv2State that so far is
+// registered only in a reload's staging registry and has not been used to
+// encrypt anything.
+func (c *cachingKMSv2State) healthCheck(ctx context.Context) error {
+	status, err := c.svc.Status(ctx)
+	if err != nil {
+		kmsv2metrics.RecordKMSOperation("status", "error")
+		return fmt.Errorf("failed to get KMSv2 plugin status for provider %q: %w", c.providerName, err)
+	}
+	kmsv2metrics.RecordKMSOperation("status", "success")
+	if status.Healthz != "ok" {
+		return fmt.Errorf("KMSv2 plugin for provider %q reported unhealthy status %q", c.providerName, status.Healthz)
+	}
+	if status.Version != c.apiVersion {
+		return fmt.Errorf("KMSv2 plugin for provider %q reported apiVersion %q, expected %q", c.providerName, status.Version, c.apiVersion)
+	}
+	if len(status.KeyId) == 0 {
+		kmsv2metrics.RecordInvalidKeyIDFromStatus()
+		return fmt.Errorf("KMSv2 plugin for provider %q reported an empty key ID", c.providerName)
+	}
+	return nil
+}
+
+// dueForProactiveRotationLocked reports whether the current DEK has hit
+// maxWritesPerDEK or maxDEKAge, so it should be replaced even though the
+// plugin's key ID hasn't changed. c.mu must be held.
+func (c *cachingKMSv2State) dueForProactiveRotationLocked() bool {
+	if c.current.keyID == "" {
+		return false
+	}
+	if c.maxWritesPerDEK > 0 && c.current.writes >= c.maxWritesPerDEK {
+		return true
+	}
+	if c.maxDEKAge > 0 && time.Since(c.current.createdAt) >= c.maxDEKAge {
+		return true
+	}
+	return false
+}
+
+// retireCurrentLocked appends the current DEK generation, if any, onto the
+// bounded retired-generation history. c.mu must be held.
+func (c *cachingKMSv2State) retireCurrentLocked() {
+	if c.current.keyID == "" {
+		return
+	}
+	c.history = append(c.history, c.current)
+	if len(c.history) > dekGenerationHistoryLimit {
+		c.history = c.history[len(c.history)-dekGenerationHistoryLimit:]
+	}
+}
+
+// rotateToLocked retires the current DEK generation, if any, and installs a
+// freshly wrapped one. c.mu must be held.
+func (c *cachingKMSv2State) rotateToLocked(keyID string, encryptedDEK []byte, annotations map[string][]byte) {
+	c.retireCurrentLocked()
+	c.state = kmsv2.State{
+		KeyID:        keyID,
+		EncryptedDEK: encryptedDEK,
+		Annotations:  annotations,
+	}
+	c.current = dekGeneration{keyID: keyID, createdAt: time.Now(), writes: 1}
+}
+
+// rotate implements the POST /kms-providers/rotate admin endpoint: it calls
+// Status immediately and, if the plugin's key ID has changed since the
+// current DEK was wrapped, invalidates the cache so the very next write
+// generates and wraps a fresh one. It reports whether a rotation happened;
+// while no DEK is cached yet, any key ID reported by Status counts as a
+// change.
+func (c *cachingKMSv2State) rotate(ctx context.Context) (bool, error) {
+	status, err := c.svc.Status(ctx)
+	if err != nil {
+		kmsv2metrics.RecordKMSOperation("status", "error")
+		return false, fmt.Errorf("failed to get KMSv2 plugin status: %w", err)
+	}
+	kmsv2metrics.RecordKMSOperation("status", "success")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if status.KeyId == c.state.KeyID {
+		return false, nil
+	}
+
+	c.retireCurrentLocked()
+	c.state = kmsv2.State{}
+	c.current = dekGeneration{}
+	return true, nil
+}
+
+// kmsv2ProviderStats is the data returned by the GET /kms-providers/stats
+// admin endpoint for a single provider.
+type kmsv2ProviderStats struct {
+	// ProviderName is the name of the KMSv2 provider these stats describe.
+	ProviderName string `json:"providerName"`
+	// CurrentKeyID is the KMS plugin key ID currently wrapping new DEKs, or
+	// empty if no DEK has been wrapped yet.
+	CurrentKeyID string `json:"currentKeyID"`
+	// LiveDEKs is the number of DEK generations still tracked for this
+	// provider: the current one plus any retired ones kept for history.
+	LiveDEKs int `json:"liveDEKs"`
+	// OldestEDEKAge is how long ago the oldest tracked DEK generation was
+	// created.
+	OldestEDEKAge time.Duration `json:"oldestEDEKAge"`
+	// WritesPerDEK is the number of objects encrypted under each tracked
+	// DEK generation, oldest first.
+	WritesPerDEK []int64 `json:"writesPerDEK"`
+}
+
+func (c *cachingKMSv2State) stats() kmsv2ProviderStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := kmsv2ProviderStats{
+		ProviderName: c.providerName,
+		CurrentKeyID: c.current.keyID,
+	}
+	generations := append(append([]dekGeneration(nil), c.history...), c.current)
+	for _, g := range generations {
+		if g.keyID == "" {
+			continue
+		}
+		stats.LiveDEKs++
+		stats.WritesPerDEK = append(stats.WritesPerDEK, g.writes)
+		if stats.OldestEDEKAge == 0 || time.Since(g.createdAt) > stats.OldestEDEKAge {
+			stats.OldestEDEKAge = time.Since(g.createdAt)
+		}
+	}
+	return stats
+}
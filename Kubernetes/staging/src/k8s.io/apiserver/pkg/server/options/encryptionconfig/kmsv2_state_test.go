@@ -0,0 +1,72 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCachingKMSv2StateGetReusesStatusWithinTTL(t *testing.T) {
+	fake := &fakeKMSv2Service{keyID: "key-1"}
+	c := &cachingKMSv2State{svc: fake, providerName: "provider-a", apiVersion: "v2"}
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.get(context.Background()); err != nil {
+			t.Fatalf("get #%d failed: %v", i, err)
+		}
+	}
+
+	// all 5 writes land within statusCacheTTL of one another, so only the
+	// first should have actually polled Status.
+	if got := fake.statusCallCount(); got != 1 {
+		t.Fatalf("expected exactly 1 Status call across 5 writes inside the TTL window, got %d", got)
+	}
+
+	state, err := c.get(context.Background())
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if state.KeyID != "key-1" {
+		t.Fatalf("expected KeyID %q, got %q", "key-1", state.KeyID)
+	}
+}
+
+func TestCachingKMSv2StateGetPollsAgainAfterTTL(t *testing.T) {
+	fake := &fakeKMSv2Service{keyID: "key-1"}
+	c := &cachingKMSv2State{svc: fake, providerName: "provider-a", apiVersion: "v2"}
+
+	if _, err := c.get(context.Background()); err != nil {
+		t.Fatalf("first get failed: %v", err)
+	}
+	if got := fake.statusCallCount(); got != 1 {
+		t.Fatalf("expected 1 Status call after the first get, got %d", got)
+	}
+
+	// force the cached Status response to be treated as stale without
+	// sleeping the test for a full statusCacheTTL.
+	c.statusMu.Lock()
+	c.statusPolledAt = c.statusPolledAt.Add(-2 * statusCacheTTL)
+	c.statusMu.Unlock()
+
+	if _, err := c.get(context.Background()); err != nil {
+		t.Fatalf("second get failed: %v", err)
+	}
+	if got := fake.statusCallCount(); got != 2 {
+		t.Fatalf("expected a second Status call once the cached response went stale, got %d", got)
+	}
+}
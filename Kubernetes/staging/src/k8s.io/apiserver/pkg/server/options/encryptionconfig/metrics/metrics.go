@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics registers the Prometheus metrics emitted by the
+// encryption configuration automatic reload controller.
+package metrics
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	reloadSuccessesTotal = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name:           "apiserver_encryption_config_controller_automatic_reload_success_total",
+			Help:           "Total number of successful automatic reloads of encryption configuration.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	reloadFailuresTotal = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name:           "apiserver_encryption_config_controller_automatic_reload_failure_total",
+			Help:           "Total number of failed automatic reloads of encryption configuration.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(reloadSuccessesTotal)
+	legacyregistry.MustRegister(reloadFailuresTotal)
+}
+
+// RecordEncryptionConfigAutomaticReloadSuccess increments the reload success
+// counter.
+func RecordEncryptionConfigAutomaticReloadSuccess() {
+	reloadSuccessesTotal.Inc()
+}
+
+// RecordEncryptionConfigAutomaticReloadFailure increments the reload failure
+// counter.
+func RecordEncryptionConfigAutomaticReloadFailure() {
+	reloadFailuresTotal.Inc()
+}
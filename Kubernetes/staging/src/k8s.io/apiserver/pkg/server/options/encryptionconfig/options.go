@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+
+	"github.com/spf13/pflag"
+)
+
+// Options holds the apiserver's --encryption-provider-config flags.
+type Options struct {
+	// EncryptionProviderConfigFilepath is the file containing configuration
+	// for encryption providers to be used for storing secrets in etcd.
+	EncryptionProviderConfigFilepath string
+
+	// EncryptionProviderConfigAutomaticReload, when true, polls
+	// EncryptionProviderConfigFilepath for changes and swaps in a newly
+	// validated transformer set without restarting the apiserver.
+	EncryptionProviderConfigAutomaticReload bool
+}
+
+// NewOptions returns Options with automatic reload disabled, matching
+// behavior before --encryption-provider-config-automatic-reload existed.
+func NewOptions() *Options {
+	return &Options{}
+}
+
+// AddFlags registers the encryption-provider-config flags on fs.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.EncryptionProviderConfigFilepath, "encryption-provider-config", o.EncryptionProviderConfigFilepath,
+		"The file containing configuration for encryption providers to be used for storing secrets in etcd.")
+	fs.BoolVar(&o.EncryptionProviderConfigAutomaticReload, "encryption-provider-config-automatic-reload", o.EncryptionProviderConfigAutomaticReload,
+		"Determines if the file set by --encryption-provider-config should be automatically reloaded if the disk contents change. "+
+			"Setting this to true disables the ability to uniquely identify distinct KMS plugins via the API server healthz endpoints.")
+}
+
+// ApplyTo loads EncryptionProviderConfigFilepath into a
+// DynamicEncryptionConfiguration and, if EncryptionProviderConfigAutomaticReload
+// is set, starts a background goroutine keeping it up to date until ctx is
+// done. It returns nil, nil if no file was configured.
+func (o *Options) ApplyTo(ctx context.Context) (*DynamicEncryptionConfiguration, error) {
+	if len(o.EncryptionProviderConfigFilepath) == 0 {
+		return nil, nil
+	}
+
+	d, err := NewDynamicEncryptionConfiguration(o.EncryptionProviderConfigFilepath)
+	if err != nil {
+		return nil, err
+	}
+	if o.EncryptionProviderConfigAutomaticReload {
+		go d.Run(ctx, DefaultReloadInterval)
+	}
+	return d, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// ProviderFactory builds the value.Transformer for a single entry of a
+// provider kind registered via RegisterProvider. rawConfig is the raw JSON
+// of that entry's value under "providers" (for example, for
+//
+//	providers:
+//	- xor:
+//	    key: "..."
+//
+// rawConfig is the bytes of {"key": "..."}. The returned HealthChecker, if
+// non-nil, is collected into DynamicEncryptionConfiguration.HealthCheckers,
+// from which callers wire it into the apiserver's healthz.
+type ProviderFactory func(ctx context.Context, rawConfig []byte) (value.Transformer, healthz.HealthChecker, error)
+
+// ProviderRegistry looks up the ProviderFactory for a provider kind name, so
+// buildPrefixTransformer does not need to hard-code every out-of-tree
+// transformer kind a caller might want to configure.
+type ProviderRegistry struct {
+	mu     sync.RWMutex
+	byName map[string]ProviderFactory
+}
+
+// NewProviderRegistry returns an empty ProviderRegistry.
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{byName: make(map[string]ProviderFactory)}
+}
+
+// DefaultProviderRegistry is the registry buildPrefixTransformer consults
+// for any provider kind it does not recognize natively. Out-of-tree
+// transformer packages call RegisterProvider on it from an init function.
+var DefaultProviderRegistry = NewProviderRegistry()
+
+// RegisterProvider adds factory to r under name, so an EncryptionConfiguration
+// entry naming "name" as a provider kind is built by factory instead of
+// failing validation. Registering the same name twice panics, matching the
+// package-level registries elsewhere in the apiserver (e.g. scheme
+// registration) that treat a duplicate registration as a programming error.
+func (r *ProviderRegistry) RegisterProvider(name string, factory ProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.byName[name]; exists {
+		panic(fmt.Sprintf("encryptionconfig: provider kind %q already registered", name))
+	}
+	r.byName[name] = factory
+}
+
+// factory returns the ProviderFactory registered under name, if any.
+func (r *ProviderRegistry) factory(name string) (ProviderFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.byName[name]
+	return f, ok
+}
+
+// RegisterProvider adds factory to the DefaultProviderRegistry under name.
+// See ProviderRegistry.RegisterProvider.
+func RegisterProvider(name string, factory ProviderFactory) {
+	DefaultProviderRegistry.RegisterProvider(name, factory)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/apis/config"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/apiserver/pkg/server/options/encryptionconfig/metrics"
+	"k8s.io/apiserver/pkg/storage/value"
+	"k8s.io/klog/v2"
+)
+
+// DefaultReloadInterval is how often a DynamicEncryptionConfiguration polls
+// its file for changes when automatic reload is enabled.
+const DefaultReloadInterval = time.Second
+
+// DynamicEncryptionConfiguration watches an EncryptionConfiguration file on
+// disk and atomically swaps in a freshly loaded, validated transformer set
+// whenever its contents change. TransformerForResource always returns either
+// the full previous set or the full new one, never a mix, so a reload can
+// never observe half-applied state. A provider removed from the file stops
+// being used to encrypt, but objects it already wrote keep decrypting as
+// long as some configured provider still recognizes their stored prefix.
+// Every KMSv2 provider named by a candidate reload must also pass a Status
+// health check before it replaces the providers currently serving traffic;
+// the old providers, and their already-established plugin connections, stay
+// in place until then.
+type DynamicEncryptionConfiguration struct {
+	filepath string
+
+	// kmsv2Registry backs the /kms-providers/rotate and /kms-providers/stats
+	// admin endpoints exposed by KMSv2AdminHandler. It is repopulated, not
+	// replaced, on every reload, so a handle obtained before a reload stays
+	// valid afterwards.
+	kmsv2Registry *kmsv2Registry
+
+	mu             sync.RWMutex
+	transformers   []resourceTransformer
+	healthCheckers []healthz.HealthChecker
+	lastHash       [sha256.Size]byte
+}
+
+// NewDynamicEncryptionConfiguration loads filepath once, synchronously, so
+// callers get an immediate error for a bad config at apiserver startup.
+func NewDynamicEncryptionConfiguration(filepath string) (*DynamicEncryptionConfiguration, error) {
+	d := &DynamicEncryptionConfiguration{filepath: filepath, kmsv2Registry: newKMSv2Registry()}
+	if err := d.reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// TransformerForResource returns the value.Transformer chain the most
+// recently loaded EncryptionConfiguration routes gr through.
+func (d *DynamicEncryptionConfiguration) TransformerForResource(gr schema.GroupResource) (value.Transformer, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return transformerForGroupResource(d.transformers, gr)
+}
+
+// HealthCheckers returns the healthz.HealthChecker, if any, that each
+// extension provider (see RegisterProvider) registered for the most
+// recently loaded EncryptionConfiguration. Callers wire these into the
+// apiserver's healthz the same way as any other HealthChecker.
+func (d *DynamicEncryptionConfiguration) HealthCheckers() []healthz.HealthChecker {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]healthz.HealthChecker(nil), d.healthCheckers...)
+}
+
+// Run polls filepath every pollInterval until ctx is done, swapping in each
+// new valid config it finds. A bad or unreadable update is logged and
+// counted in the apiserver_encryption_config_controller_automatic_reload_*
+// metrics, and the previous, still-valid transformer set keeps serving.
+func (d *DynamicEncryptionConfiguration) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.reload(ctx); err != nil {
+				klog.ErrorS(err, "failed to reload encryption configuration", "file", d.filepath)
+			}
+		}
+	}
+}
+
+func (d *DynamicEncryptionConfiguration) reload(ctx context.Context) error {
+	data, err := os.ReadFile(d.filepath)
+	if err != nil {
+		metrics.RecordEncryptionConfigAutomaticReloadFailure()
+		return fmt.Errorf("failed to read encryption provider config %q: %w", d.filepath, err)
+	}
+	hash := sha256.Sum256(data)
+
+	d.mu.RLock()
+	unchanged := d.transformers != nil && hash == d.lastHash
+	d.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cfg, err := LoadEncryptionConfig(data)
+	if err != nil {
+		metrics.RecordEncryptionConfigAutomaticReloadFailure()
+		return fmt.Errorf("failed to load encryption provider config %q: %w", d.filepath, err)
+	}
+
+	// Build the replacement providers into a throwaway registry rather than
+	// d.kmsv2Registry, so a KMSv2 plugin that is still starting up (or has
+	// gone away) fails health checking below without ever tearing down the
+	// providers currently serving traffic.
+	staging := newKMSv2Registry()
+	transformers, healthCheckers, err := buildTransformers(ctx, cfg, staging)
+	if err != nil {
+		metrics.RecordEncryptionConfigAutomaticReloadFailure()
+		return fmt.Errorf("failed to build transformers from encryption provider config %q: %w", d.filepath, err)
+	}
+	for _, state := range staging.all() {
+		if err := state.healthCheck(ctx); err != nil {
+			metrics.RecordEncryptionConfigAutomaticReloadFailure()
+			return fmt.Errorf("failed health check for encryption provider config %q: %w", d.filepath, err)
+		}
+	}
+
+	d.mu.Lock()
+	d.transformers = transformers
+	d.healthCheckers = healthCheckers
+	d.lastHash = hash
+	d.mu.Unlock()
+	d.kmsv2Registry.replaceFrom(staging)
+
+	metrics.RecordEncryptionConfigAutomaticReloadSuccess()
+	return nil
+}
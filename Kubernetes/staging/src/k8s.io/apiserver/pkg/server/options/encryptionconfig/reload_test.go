@@ -0,0 +1,339 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/storage/value"
+	kmsv2api "k8s.io/kms/apis/v2"
+	kmsv2svc "k8s.io/kms/pkg/service"
+)
+
+const identityOnlyConfig = `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - identity: {}
+`
+
+const aesGCMConfig = `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - aesgcm:
+        keys:
+        - name: key1
+          secret: MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=
+    - identity: {}
+`
+
+func writeConfig(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write encryption config: %v", err)
+	}
+}
+
+func TestDynamicEncryptionConfigurationReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "encryption-config.yaml")
+	writeConfig(t, path, identityOnlyConfig)
+
+	d, err := NewDynamicEncryptionConfiguration(path)
+	if err != nil {
+		t.Fatalf("NewDynamicEncryptionConfiguration failed: %v", err)
+	}
+
+	secrets := schema.GroupResource{Resource: "secrets"}
+	dataCtx := value.DefaultContext("ctx")
+	plaintext := []byte("hello")
+
+	transformer, ok := d.TransformerForResource(secrets)
+	if !ok {
+		t.Fatalf("expected a transformer for secrets")
+	}
+	oldEncrypted, err := transformer.TransformToStorage(context.Background(), plaintext, dataCtx)
+	if err != nil {
+		t.Fatalf("TransformToStorage failed: %v", err)
+	}
+
+	// rewrite the file to add an aesgcm provider ahead of identity.
+	writeConfig(t, path, aesGCMConfig)
+	if err := d.reload(context.Background()); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	newTransformer, ok := d.TransformerForResource(secrets)
+	if !ok {
+		t.Fatalf("expected a transformer for secrets after reload")
+	}
+	newEncrypted, err := newTransformer.TransformToStorage(context.Background(), plaintext, dataCtx)
+	if err != nil {
+		t.Fatalf("TransformToStorage after reload failed: %v", err)
+	}
+	if bytes.Equal(newEncrypted, oldEncrypted) {
+		t.Fatalf("expected newly written data to use the new aesgcm provider, not identity")
+	}
+
+	// rows written under the old, identity-only config must still decrypt.
+	decrypted, _, err := newTransformer.TransformFromStorage(context.Background(), oldEncrypted, dataCtx)
+	if err != nil {
+		t.Fatalf("expected identity-encrypted row to still decrypt after reload: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("decrypted mismatch: got %q, want %q", decrypted, plaintext)
+	}
+}
+
+func TestDynamicEncryptionConfigurationRejectsInvalidReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "encryption-config.yaml")
+	writeConfig(t, path, identityOnlyConfig)
+
+	d, err := NewDynamicEncryptionConfiguration(path)
+	if err != nil {
+		t.Fatalf("NewDynamicEncryptionConfiguration failed: %v", err)
+	}
+
+	writeConfig(t, path, `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - '*.*'
+    providers:
+    - identity: {}
+  - resources:
+    - secrets
+    providers:
+    - identity: {}
+`)
+	if err := d.reload(context.Background()); err == nil {
+		t.Fatalf("expected reload to reject a config where \"secrets\" is shadowed by an earlier \"*.*\" wildcard")
+	}
+
+	// the previous, valid config must still be in effect.
+	if _, ok := d.TransformerForResource(schema.GroupResource{Resource: "secrets"}); !ok {
+		t.Fatalf("expected previous transformer set to keep serving after a rejected reload")
+	}
+}
+
+func TestDynamicEncryptionConfigurationKMSv2ProviderRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "encryption-config.yaml")
+
+	oldFactory := EnvelopeKMSv2ServiceFactory
+	defer func() { EnvelopeKMSv2ServiceFactory = oldFactory }()
+	EnvelopeKMSv2ServiceFactory = func(ctx context.Context, endpoint, providerName string, callTimeout time.Duration) (kmsv2svc.Service, error) {
+		return &fakeKMSv2Service{keyID: providerName + "-key"}, nil
+	}
+
+	writeConfig(t, path, `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - kms:
+        apiVersion: v2
+        name: provider-a
+        endpoint: unix:///provider-a.sock
+`)
+
+	d, err := NewDynamicEncryptionConfiguration(path)
+	if err != nil {
+		t.Fatalf("NewDynamicEncryptionConfiguration failed: %v", err)
+	}
+
+	secrets := schema.GroupResource{Resource: "secrets"}
+	dataCtx := value.DefaultContext("ctx")
+	plaintext := []byte("rotate me")
+
+	transformer, _ := d.TransformerForResource(secrets)
+	oldEncrypted, err := transformer.TransformToStorage(context.Background(), plaintext, dataCtx)
+	if err != nil {
+		t.Fatalf("TransformToStorage failed: %v", err)
+	}
+
+	// rotate to a new primary provider while keeping the old one listed as a
+	// secondary, so objects it already wrote keep decrypting until a
+	// storage migration re-encrypts them under provider-b.
+	writeConfig(t, path, `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - kms:
+        apiVersion: v2
+        name: provider-b
+        endpoint: unix:///provider-b.sock
+    - kms:
+        apiVersion: v2
+        name: provider-a
+        endpoint: unix:///provider-a.sock
+`)
+	if err := d.reload(context.Background()); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	newTransformer, _ := d.TransformerForResource(secrets)
+	// records written under the old provider's prefix must still decrypt,
+	// since value.NewPrefixTransformers dispatches on the stored prefix
+	// rather than which provider is now primary.
+	decrypted, _, err := newTransformer.TransformFromStorage(context.Background(), oldEncrypted, dataCtx)
+	if err != nil {
+		t.Fatalf("expected provider-a-encrypted row to still decrypt after rotating provider-b to primary: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("decrypted mismatch: got %q, want %q", decrypted, plaintext)
+	}
+}
+
+// fakeKMSv2Service's Status reports healthy unless unhealthy is set, so
+// tests of the reload health gate can flip a single field rather than
+// standing up a second fake type.
+func TestDynamicEncryptionConfigurationReloadRejectsUnhealthyKMSv2Provider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "encryption-config.yaml")
+
+	oldFactory := EnvelopeKMSv2ServiceFactory
+	defer func() { EnvelopeKMSv2ServiceFactory = oldFactory }()
+	services := map[string]*fakeKMSv2Service{
+		"provider-a": {keyID: "provider-a-key"},
+		"provider-b": {keyID: "provider-b-key", unhealthy: true},
+	}
+	EnvelopeKMSv2ServiceFactory = func(ctx context.Context, endpoint, providerName string, callTimeout time.Duration) (kmsv2svc.Service, error) {
+		return services[providerName], nil
+	}
+
+	writeConfig(t, path, `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - kms:
+        apiVersion: v2
+        name: provider-a
+        endpoint: unix:///provider-a.sock
+`)
+
+	d, err := NewDynamicEncryptionConfiguration(path)
+	if err != nil {
+		t.Fatalf("NewDynamicEncryptionConfiguration failed: %v", err)
+	}
+
+	secrets := schema.GroupResource{Resource: "secrets"}
+	dataCtx := value.DefaultContext("ctx")
+	plaintext := []byte("stay on provider-a")
+
+	transformer, _ := d.TransformerForResource(secrets)
+	encrypted, err := transformer.TransformToStorage(context.Background(), plaintext, dataCtx)
+	if err != nil {
+		t.Fatalf("TransformToStorage failed: %v", err)
+	}
+
+	// provider-b's plugin is unhealthy, so this reload must be rejected and
+	// provider-a must keep serving.
+	writeConfig(t, path, `
+kind: EncryptionConfiguration
+apiVersion: apiserver.config.k8s.io/v1
+resources:
+  - resources:
+    - secrets
+    providers:
+    - kms:
+        apiVersion: v2
+        name: provider-b
+        endpoint: unix:///provider-b.sock
+`)
+	if err := d.reload(context.Background()); err == nil {
+		t.Fatalf("expected reload to reject a config naming an unhealthy KMSv2 provider")
+	}
+
+	stillTransformer, ok := d.TransformerForResource(secrets)
+	if !ok {
+		t.Fatalf("expected previous transformer set to keep serving after a rejected reload")
+	}
+	decrypted, _, err := stillTransformer.TransformFromStorage(context.Background(), encrypted, dataCtx)
+	if err != nil {
+		t.Fatalf("expected provider-a-encrypted row to still decrypt after a rejected reload: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("decrypted mismatch: got %q, want %q", decrypted, plaintext)
+	}
+
+	// provider-a must still be reachable through the admin registry too,
+	// proving the staging registry built for the failed reload never
+	// replaced it.
+	if _, ok := d.kmsv2Registry.get("provider-a"); !ok {
+		t.Fatalf("expected provider-a to remain registered after a rejected reload")
+	}
+	if _, ok := d.kmsv2Registry.get("provider-b"); ok {
+		t.Fatalf("expected provider-b to never be published to the registry since it failed health checking")
+	}
+}
+
+type fakeKMSv2Service struct {
+	keyID     string
+	unhealthy bool
+
+	mu          sync.Mutex
+	statusCalls int
+}
+
+func (f *fakeKMSv2Service) Decrypt(_ context.Context, _ string, req *kmsv2api.DecryptRequest) ([]byte, error) {
+	return req.Ciphertext, nil
+}
+
+func (f *fakeKMSv2Service) Encrypt(_ context.Context, _ string, plaintext []byte) (*kmsv2api.EncryptResponse, error) {
+	return &kmsv2api.EncryptResponse{Ciphertext: plaintext, KeyId: f.keyID}, nil
+}
+
+func (f *fakeKMSv2Service) Status(_ context.Context) (*kmsv2api.StatusResponse, error) {
+	f.mu.Lock()
+	f.statusCalls++
+	f.mu.Unlock()
+	if f.unhealthy {
+		return &kmsv2api.StatusResponse{KeyId: f.keyID, Healthz: "err", Version: "v2"}, nil
+	}
+	return &kmsv2api.StatusResponse{KeyId: f.keyID, Healthz: "ok", Version: "v2"}, nil
+}
+
+func (f *fakeKMSv2Service) statusCallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statusCalls
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryptionconfig
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/apis/config"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/apiserver/pkg/storage/value"
+	aestransformer "k8s.io/apiserver/pkg/storage/value/encrypt/aes"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2"
+	kmstypes "k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2/v2"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/identity"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/secretbox"
+)
+
+// defaultKMSv2CallTimeout bounds how long a single call to a KMSv2 plugin
+// (Status, Encrypt or Decrypt) may take before it is treated as failed, for
+// a provider whose config leaves Timeout unset.
+const defaultKMSv2CallTimeout = 3 * time.Second
+
+// resourceTransformer pairs one resource spec listed by a
+// ResourceConfiguration with the value.Transformer built from that entry's
+// Providers, tried in the configured
+// order: the first provider is used to encrypt, and TransformFromStorage
+// tries each in turn (via value.NewPrefixTransformers' stored-prefix
+// dispatch) so objects written under a provider that has since been
+// reordered, or removed from the front of the list, still decrypt.
+type resourceTransformer struct {
+	spec        resourceSpec
+	transformer value.Transformer
+}
+
+// buildTransformers constructs the value.Transformer for every
+// ResourceConfiguration entry in cfg, in the style of
+// GetTransformerOverrides in the real apiserver: one entry's Providers
+// become a single prefix-dispatching value.Transformer, keyed by every
+// resource spec (including wildcards) that entry lists.
+func buildTransformers(ctx context.Context, cfg *config.EncryptionConfiguration, registry *kmsv2Registry) ([]resourceTransformer, []healthz.HealthChecker, error) {
+	var out []resourceTransformer
+	var healthCheckers []healthz.HealthChecker
+	for _, rc := range cfg.Resources {
+		transformer, checkers, err := buildPrefixTransformer(ctx, rc.Providers, registry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build transformer for resources %v: %w", rc.Resources, err)
+		}
+		healthCheckers = append(healthCheckers, checkers...)
+		for _, raw := range rc.Resources {
+			out = append(out, resourceTransformer{spec: parseResourceSpec(raw), transformer: transformer})
+		}
+	}
+	return out, healthCheckers, nil
+}
+
+// transformerForGroupResource returns the first transformer in transformers
+// whose spec matches gr, mirroring the first-match-wins semantics of
+// ResourceConfigurationForGroupResource.
+func transformerForGroupResource(transformers []resourceTransformer, gr schema.GroupResource) (value.Transformer, bool) {
+	for _, rt := range transformers {
+		if rt.spec.matches(gr) {
+			return rt.transformer, true
+		}
+	}
+	return nil, false
+}
+
+func buildPrefixTransformer(ctx context.Context, providers []config.ProviderConfiguration, registry *kmsv2Registry) (value.Transformer, []healthz.HealthChecker, error) {
+	var prefixed []value.PrefixTransformer
+	var healthCheckers []healthz.HealthChecker
+	for i, p := range providers {
+		switch {
+		case p.Identity != nil:
+			prefixed = append(prefixed, value.PrefixTransformer{
+				Prefix:      []byte("k8s:enc:identity:v1:"),
+				Transformer: identity.NewEncryptCheckTransformer(),
+			})
+
+		case p.AESGCM != nil:
+			t, err := aesPrefixTransformers(p.AESGCM.Keys, "k8s:enc:aesgcm:v1:", aestransformer.NewGCMTransformer)
+			if err != nil {
+				return nil, nil, err
+			}
+			prefixed = append(prefixed, t...)
+
+		case p.AESCBC != nil:
+			t, err := aesPrefixTransformers(p.AESCBC.Keys, "k8s:enc:aescbc:v1:", aestransformer.NewCBCTransformer)
+			if err != nil {
+				return nil, nil, err
+			}
+			prefixed = append(prefixed, t...)
+
+		case p.Secretbox != nil:
+			for _, k := range p.Secretbox.Keys {
+				key, err := base64.StdEncoding.DecodeString(k.Secret)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid base64 secretbox key %q: %w", k.Name, err)
+				}
+				var sb [32]byte
+				if n := copy(sb[:], key); n != 32 {
+					return nil, nil, fmt.Errorf("secretbox key %q must decode to 32 bytes, got %d", k.Name, n)
+				}
+				prefixed = append(prefixed, value.PrefixTransformer{
+					Prefix:      []byte(fmt.Sprintf("k8s:enc:secretbox:v1:%s:", k.Name)),
+					Transformer: secretbox.NewSecretboxTransformer(sb),
+				})
+			}
+
+		case p.KMS != nil:
+			if p.KMS.APIVersion != "v2" {
+				return nil, nil, fmt.Errorf("kms provider %q: only APIVersion v2 is supported", p.KMS.Name)
+			}
+			callTimeout := defaultKMSv2CallTimeout
+			if p.KMS.Timeout.Duration > 0 {
+				callTimeout = p.KMS.Timeout.Duration
+			}
+			svc, err := EnvelopeKMSv2ServiceFactory(ctx, p.KMS.Endpoint, p.KMS.Name, callTimeout)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to configure KMSv2 provider %q: %w", p.KMS.Name, err)
+			}
+			var maxWritesPerDEK int64
+			if p.KMS.MaxWritesPerDEK != nil {
+				maxWritesPerDEK = *p.KMS.MaxWritesPerDEK
+			}
+			var maxDEKAge time.Duration
+			if p.KMS.MaxDEKAge != nil {
+				maxDEKAge = p.KMS.MaxDEKAge.Duration
+			}
+			algorithm, err := parseKMSv2Algorithm(p.KMS.Algorithm)
+			if err != nil {
+				return nil, nil, fmt.Errorf("kms provider %q: %w", p.KMS.Name, err)
+			}
+			prefixed = append(prefixed, value.PrefixTransformer{
+				Prefix:      []byte(fmt.Sprintf("k8s:enc:kms:v2:%s:", p.KMS.Name)),
+				Transformer: kmsv2.NewEnvelopeTransformer(svc, p.KMS.Name, newCachingKMSv2StateFunc(svc, p.KMS.Name, p.KMS.APIVersion, maxWritesPerDEK, maxDEKAge, registry), algorithm),
+			})
+
+		case len(p.Extensions) == 1:
+			t, checker, err := buildExtensionTransformer(ctx, p.Extensions)
+			if err != nil {
+				return nil, nil, err
+			}
+			if checker != nil {
+				healthCheckers = append(healthCheckers, checker)
+			}
+			prefixed = append(prefixed, *t)
+
+		case len(p.Extensions) > 1:
+			return nil, nil, fmt.Errorf("provider at index %d names more than one unrecognized provider kind", i)
+
+		default:
+			return nil, nil, fmt.Errorf("provider at index %d has no recognized type set", i)
+		}
+	}
+	if len(prefixed) == 0 {
+		return nil, nil, fmt.Errorf("no providers configured")
+	}
+	return value.NewPrefixTransformers(fmt.Errorf("no matching prefix found"), prefixed...), healthCheckers, nil
+}
+
+// buildExtensionTransformer builds the value.PrefixTransformer for a
+// provider kind registered via RegisterProvider. extensions holds exactly
+// one entry: the kind name and its raw, not-yet-parsed configuration.
+func buildExtensionTransformer(ctx context.Context, extensions map[string][]byte) (*value.PrefixTransformer, healthz.HealthChecker, error) {
+	var kind string
+	var rawConfig []byte
+	for k, v := range extensions {
+		kind, rawConfig = k, v
+	}
+
+	factory, ok := DefaultProviderRegistry.factory(kind)
+	if !ok {
+		return nil, nil, fmt.Errorf("no provider registered for kind %q; did you forget to import the package that calls encryptionconfig.RegisterProvider?", kind)
+	}
+	transformer, checker, err := factory(ctx, rawConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("provider kind %q: %w", kind, err)
+	}
+	return &value.PrefixTransformer{
+		Prefix:      []byte(fmt.Sprintf("k8s:enc:%s:v1:", kind)),
+		Transformer: transformer,
+	}, checker, nil
+}
+
+// parseKMSv2Algorithm maps the user-facing --encryption-provider-config
+// "algorithm" string to the kmstypes.Algorithm stored on every
+// EncryptedObject. An empty string, matching configs written before
+// Algorithm existed, keeps the historical default of AES-GCM.
+func parseKMSv2Algorithm(raw string) (kmstypes.Algorithm, error) {
+	switch raw {
+	case "", "AES-GCM":
+		return kmstypes.Algorithm_AES_GCM, nil
+	case "AES-GCM-SIV":
+		return kmstypes.Algorithm_AES_GCM_SIV, nil
+	case "ChaCha20-Poly1305":
+		return kmstypes.Algorithm_CHACHA20_POLY1305, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm %q", raw)
+	}
+}
+
+func aesPrefixTransformers(keys []config.Key, prefix string, newTransformer func(cipher.Block) (value.Transformer, error)) ([]value.PrefixTransformer, error) {
+	var out []value.PrefixTransformer
+	for _, k := range keys {
+		key, err := base64.StdEncoding.DecodeString(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 key %q: %w", k.Name, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct AES cipher for key %q: %w", k.Name, err)
+		}
+		transformer, err := newTransformer(block)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, value.PrefixTransformer{
+			Prefix:      []byte(fmt.Sprintf("%s%s:", prefix, k.Name)),
+			Transformer: transformer,
+		})
+	}
+	return out, nil
+}
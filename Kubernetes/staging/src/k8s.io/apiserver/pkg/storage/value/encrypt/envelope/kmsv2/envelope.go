@@ -0,0 +1,398 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kmsv2 transforms values for storage at rest using an envelope
+// scheme: each value is sealed with a data encryption key (DEK), and the DEK
+// itself is wrapped by a remote KMS plugin so only the wrapped form needs to
+// be persisted alongside the ciphertext.
+package kmsv2
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	sivaead "github.com/secure-io/siv-go"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	"k8s.io/apiserver/pkg/storage/value"
+	aestransformer "k8s.io/apiserver/pkg/storage/value/encrypt/aes"
+	kmsv2metrics "k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2/metrics"
+	kmstypes "k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2/v2"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	kmsv2api "k8s.io/kms/apis/v2"
+	kmsv2svc "k8s.io/kms/pkg/service"
+)
+
+const (
+	// kdfSeedLength is the length, in bytes, of the seed a KMS plugin
+	// returns in place of a DEK when the KMSv2KDF feature is enabled.
+	kdfSeedLength = 32
+	// kdfInfoLength is the length, in bytes, of the random HKDF info value
+	// generated per object and stored in EncryptedObject.KeyDerivationInfo.
+	kdfInfoLength = 32
+	// kdfKeyLength is the length, in bytes, of the AES-256 key HKDF derives.
+	kdfKeyLength = 32
+	// nonceLength is the length, in bytes, of an AES-GCM nonce.
+	nonceLength = 12
+)
+
+// StateFunc returns the DEK (or, in KDF mode, the seed) that should be used
+// to encrypt the next value, generating and wrapping a new one via the KMS
+// plugin if the cached one has expired or the plugin's keyID changed.
+type StateFunc func(ctx context.Context) (State, error)
+
+// State is the generate-or-fetch result for the currently active DEK/seed.
+type State struct {
+	// KeyID is the KMS plugin's current key ID, recorded so readers can tell
+	// which key wrapped EncryptedDEK.
+	KeyID string
+	// EncryptedDEK is the KMS-wrapped DEK (or KDF seed).
+	EncryptedDEK []byte
+	// Annotations is additional metadata returned by the KMS plugin.
+	Annotations map[string][]byte
+}
+
+// envelopeTransformer implements value.Transformer using envelope encryption:
+// TransformToStorage asks stateFunc for the DEK/seed to use, seals the value
+// with it, and stores both the ciphertext and the wrapped DEK/seed.
+// TransformFromStorage reverses this, caching unwrapped DEKs (and, in KDF
+// mode, unwrapped seeds) by their wrapped form so a single KMS Decrypt call
+// serves every object that shares an EncryptedDEK.
+type envelopeTransformer struct {
+	envelopeService kmsv2svc.Service
+	providerName    string
+	stateFunc       StateFunc
+	// algorithm is the AEAD cipher new writes seal EncryptedData with. Reads
+	// always dispatch on the stored object's own Algorithm field instead, so
+	// objects written under a previously configured algorithm keep
+	// decrypting after algorithm is changed.
+	algorithm kmstypes.Algorithm
+
+	cacheMu sync.RWMutex
+	cache   map[string]value.Transformer
+
+	// seedCacheMu and seedCache cache unwrapped KDF seeds by EncryptedDEK,
+	// the KDF-mode counterpart of cache above. They are kept separate since
+	// a seed is not itself a value.Transformer: callers still need the
+	// per-object KeyDerivationInfo to derive one.
+	seedCacheMu sync.RWMutex
+	seedCache   map[string][]byte
+
+	// kdfNonce is only used in KDF mode, where the AES key is unique per
+	// object, so the nonce only needs to avoid repeating within that single
+	// key's lifetime and a monotonic counter is sufficient.
+	nonceMu  sync.Mutex
+	kdfNonce uint64
+}
+
+// NewEnvelopeTransformer returns a value.Transformer that implements
+// envelope encryption, unwrapping/wrapping DEKs through envelopeService and
+// sealing new writes with algorithm (ignored when the KMSv2KDF feature is
+// enabled, which always uses AES-GCM with a per-object derived key).
+func NewEnvelopeTransformer(envelopeService kmsv2svc.Service, providerName string, stateFunc StateFunc, algorithm kmstypes.Algorithm) value.Transformer {
+	return &envelopeTransformer{
+		envelopeService: envelopeService,
+		providerName:    providerName,
+		stateFunc:       stateFunc,
+		algorithm:       algorithm,
+		cache:           make(map[string]value.Transformer),
+		seedCache:       make(map[string][]byte),
+	}
+}
+
+// TransformToStorage encrypts data and returns the marshaled EncryptedObject
+// to persist. When the KMSv2KDF feature is enabled, the value unwrapped from
+// EncryptedDEK (cached via cachedSeed) is treated as a seed and a fresh
+// per-object AES key is derived via HKDF-SHA256 over it and a random
+// KeyDerivationInfo value, so the seed itself is never used directly for
+// AES-GCM.
+func (t *envelopeTransformer) TransformToStorage(ctx context.Context, data []byte, dataCtx value.Context) ([]byte, error) {
+	state, err := t.stateFunc(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current KMSv2 state: %w", err)
+	}
+
+	out := &kmstypes.EncryptedObject{
+		KeyID:        state.KeyID,
+		EncryptedDEK: state.EncryptedDEK,
+		Annotations:  state.Annotations,
+	}
+
+	if utilfeature.DefaultFeatureGate.Enabled(KMSv2KDFFeature) {
+		seed, err := t.cachedSeed(ctx, out)
+		if err != nil {
+			return nil, err
+		}
+		info := make([]byte, kdfInfoLength)
+		if _, err := rand.Read(info); err != nil {
+			return nil, fmt.Errorf("failed to generate KDF info: %w", err)
+		}
+		encryptedData, err := t.sealWithDerivedKey(seed, info, data, dataCtx)
+		if err != nil {
+			return nil, err
+		}
+		out.EncryptedData = encryptedData
+		out.KeyDerivationInfo = info
+		out.Algorithm = kmstypes.Algorithm_AES_GCM
+		return proto.Marshal(out)
+	}
+
+	out.Algorithm = t.algorithm
+	transformer, err := t.dekTransformer(ctx, state.EncryptedDEK, state.KeyID, out.Annotations, t.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	encryptedData, err := transformer.TransformToStorage(ctx, data, dataCtx)
+	if err != nil {
+		return nil, err
+	}
+	out.EncryptedData = encryptedData
+	return proto.Marshal(out)
+}
+
+// TransformFromStorage decrypts data previously written by TransformToStorage.
+// It dispatches on whether KeyDerivationInfo is set so objects written under
+// either mode stay readable even if the KMSv2KDF feature gate is flipped
+// mid-run.
+func (t *envelopeTransformer) TransformFromStorage(ctx context.Context, data []byte, dataCtx value.Context) ([]byte, bool, error) {
+	o := &kmstypes.EncryptedObject{}
+	if err := proto.Unmarshal(data, o); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal EncryptedObject: %w", err)
+	}
+
+	if len(o.KeyDerivationInfo) > 0 {
+		seed, err := t.cachedSeed(ctx, o)
+		if err != nil {
+			return nil, false, err
+		}
+		return t.openWithDerivedKey(seed, o.KeyDerivationInfo, o.EncryptedData, dataCtx)
+	}
+
+	transformer, err := t.dekTransformer(ctx, o.EncryptedDEK, o.KeyID, o.Annotations, o.Algorithm)
+	if err != nil {
+		return nil, false, err
+	}
+	return transformer.TransformFromStorage(ctx, o.EncryptedData, dataCtx)
+}
+
+// dekTransformer returns the value.Transformer for encryptedDEK under
+// algorithm, populating the cache on first use. Dispatching on the stored
+// object's own algorithm (rather than always using t.algorithm) is what lets
+// TransformFromStorage read objects written under an algorithm that has
+// since been changed in the provider's configuration.
+func (t *envelopeTransformer) dekTransformer(ctx context.Context, encryptedDEK []byte, keyID string, annotations map[string][]byte, algorithm kmstypes.Algorithm) (value.Transformer, error) {
+	cacheKey := fmt.Sprintf("%d:%s", algorithm, encryptedDEK)
+
+	t.cacheMu.RLock()
+	transformer, ok := t.cache[cacheKey]
+	t.cacheMu.RUnlock()
+	if ok {
+		return transformer, nil
+	}
+
+	dek, err := t.unwrapDEK(ctx, &kmstypes.EncryptedObject{EncryptedDEK: encryptedDEK, KeyID: keyID, Annotations: annotations})
+	if err != nil {
+		return nil, err
+	}
+
+	if algorithm == kmstypes.Algorithm_AES_GCM {
+		// the default, unchanged since before Algorithm existed: keep using
+		// aestransformer's historical nonce layout so already-written
+		// objects, which never recorded an Algorithm, keep decrypting.
+		block, err := aes.NewCipher(dek)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct AES cipher from DEK: %w", err)
+		}
+		transformer, err = aestransformer.NewGCMTransformer(block)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		transformer, err = newAEADTransformer(algorithm, dek)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t.cacheMu.Lock()
+	t.cache[cacheKey] = transformer
+	t.cacheMu.Unlock()
+	return transformer, nil
+}
+
+// cachedSeed returns the unwrapped KDF seed for o.EncryptedDEK, populating
+// seedCache on first use so that every object sealed under the same seed
+// (they differ only in their own KeyDerivationInfo) shares a single KMS
+// Decrypt call, the KDF-mode counterpart of dekTransformer's cache.
+func (t *envelopeTransformer) cachedSeed(ctx context.Context, o *kmstypes.EncryptedObject) ([]byte, error) {
+	cacheKey := string(o.EncryptedDEK)
+
+	t.seedCacheMu.RLock()
+	seed, ok := t.seedCache[cacheKey]
+	t.seedCacheMu.RUnlock()
+	if ok {
+		return seed, nil
+	}
+
+	seed, err := t.unwrapDEK(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	t.seedCacheMu.Lock()
+	t.seedCache[cacheKey] = seed
+	t.seedCacheMu.Unlock()
+	return seed, nil
+}
+
+// aeadTransformer is a value.Transformer backed directly by a cipher.AEAD.
+// It is used for every KMSv2 Algorithm other than the default AES-GCM,
+// which keeps using aestransformer.NewGCMTransformer for its historical
+// nonce layout. It prefixes each ciphertext with a random nonce sized to
+// the AEAD, which is safe for every algorithm kmsv2 supports, including
+// ones like AES-GCM-SIV that additionally tolerate nonce reuse.
+type aeadTransformer struct {
+	aead cipher.AEAD
+}
+
+// newAEADTransformer constructs the value.Transformer for algorithm, keyed
+// by key, which must already be the correct length for that algorithm.
+func newAEADTransformer(algorithm kmstypes.Algorithm, key []byte) (value.Transformer, error) {
+	aead, err := newAEAD(algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	return &aeadTransformer{aead: aead}, nil
+}
+
+// newAEAD constructs the cipher.AEAD for algorithm.
+func newAEAD(algorithm kmstypes.Algorithm, key []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case kmstypes.Algorithm_AES_GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct AES cipher: %w", err)
+		}
+		return cipher.NewGCM(block)
+	case kmstypes.Algorithm_AES_GCM_SIV:
+		return sivaead.NewGCM(key)
+	case kmstypes.Algorithm_CHACHA20_POLY1305:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("unsupported KMSv2 algorithm %v", algorithm)
+	}
+}
+
+func (t *aeadTransformer) TransformToStorage(ctx context.Context, data []byte, dataCtx value.Context) ([]byte, error) {
+	nonce := make([]byte, t.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return t.aead.Seal(nonce, nonce, data, dataCtx.AuthenticatedData()), nil
+}
+
+func (t *aeadTransformer) TransformFromStorage(ctx context.Context, data []byte, dataCtx value.Context) ([]byte, bool, error) {
+	nonceSize := t.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, false, fmt.Errorf("invalid data: shorter than the %d-byte nonce", nonceSize)
+	}
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	out, err := t.aead.Open(nil, nonce, ciphertext, dataCtx.AuthenticatedData())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return out, false, nil
+}
+
+func (t *envelopeTransformer) unwrapDEK(ctx context.Context, o *kmstypes.EncryptedObject) ([]byte, error) {
+	dek, err := t.envelopeService.Decrypt(ctx, "", &kmsv2api.DecryptRequest{
+		Ciphertext:  o.EncryptedDEK,
+		KeyId:       o.KeyID,
+		Annotations: o.Annotations,
+	})
+	if err != nil {
+		kmsv2metrics.RecordKMSOperation("decrypt", "error")
+		return nil, fmt.Errorf("failed to decrypt DEK, KMSv2 provider %q: %w", t.providerName, err)
+	}
+	kmsv2metrics.RecordKMSOperation("decrypt", "success")
+	return dek, nil
+}
+
+// sealWithDerivedKey derives a per-object AES key from seed and info and
+// encrypts data with it, using a monotonically increasing nonce since the
+// key itself is never reused across objects.
+func (t *envelopeTransformer) sealWithDerivedKey(seed, info, data []byte, dataCtx value.Context) ([]byte, error) {
+	aead, err := newDerivedAEAD(seed, info)
+	if err != nil {
+		return nil, err
+	}
+	nonce := t.nextKDFNonce()
+	return aead.Seal(nonce, nonce, data, dataCtx.AuthenticatedData()), nil
+}
+
+func (t *envelopeTransformer) openWithDerivedKey(seed, info, encryptedData []byte, dataCtx value.Context) ([]byte, bool, error) {
+	aead, err := newDerivedAEAD(seed, info)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(encryptedData) < nonceLength {
+		return nil, false, fmt.Errorf("invalid KMSv2 KDF payload: shorter than nonce")
+	}
+	nonce, ciphertext := encryptedData[:nonceLength], encryptedData[nonceLength:]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, dataCtx.AuthenticatedData())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decrypt KMSv2 KDF payload: %w", err)
+	}
+	return plaintext, false, nil
+}
+
+func (t *envelopeTransformer) nextKDFNonce() []byte {
+	t.nonceMu.Lock()
+	defer t.nonceMu.Unlock()
+	t.kdfNonce++
+
+	nonce := make([]byte, nonceLength)
+	binary.LittleEndian.PutUint64(nonce, t.kdfNonce)
+	return nonce
+}
+
+// newDerivedAEAD derives a kdfKeyLength-byte AES-GCM key from seed via
+// HKDF-SHA256, with info as the HKDF info parameter and no salt, and
+// constructs the corresponding AEAD.
+// Per the KMSv2KDF contract, seed itself must never be used directly for
+// AES-GCM; only the derived key is.
+func newDerivedAEAD(seed, info []byte) (cipher.AEAD, error) {
+	if len(seed) != kdfSeedLength {
+		return nil, fmt.Errorf("KMSv2 seed must be %d bytes when KMSv2KDF is enabled, got %d", kdfSeedLength, len(seed))
+	}
+	key := make([]byte, kdfKeyLength)
+	if _, err := hkdf.New(sha256.New, seed, nil, info).Read(key); err != nil {
+		return nil, fmt.Errorf("failed to derive per-object key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct AES cipher from derived key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kmsv2
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"k8s.io/apiserver/pkg/storage/value"
+	kmstypes "k8s.io/apiserver/pkg/storage/value/encrypt/envelope/kmsv2/v2"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	kmsv2api "k8s.io/kms/apis/v2"
+)
+
+// fakeEnvelopeService implements kmsv2svc.Service with a no-op "encryption":
+// Encrypt and Decrypt return their input unchanged, which is all the
+// envelope transformer needs to exercise its own logic independent of a
+// real KMS plugin.
+type fakeEnvelopeService struct {
+	keyID string
+
+	mu           sync.Mutex
+	decryptCalls int
+}
+
+func (f *fakeEnvelopeService) Decrypt(_ context.Context, _ string, req *kmsv2api.DecryptRequest) ([]byte, error) {
+	f.mu.Lock()
+	f.decryptCalls++
+	f.mu.Unlock()
+	return req.Ciphertext, nil
+}
+
+func (f *fakeEnvelopeService) decryptCallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.decryptCalls
+}
+
+func (f *fakeEnvelopeService) Encrypt(_ context.Context, _ string, plaintext []byte) (*kmsv2api.EncryptResponse, error) {
+	return &kmsv2api.EncryptResponse{Ciphertext: plaintext, KeyId: f.keyID}, nil
+}
+
+func (f *fakeEnvelopeService) Status(_ context.Context) (*kmsv2api.StatusResponse, error) {
+	return &kmsv2api.StatusResponse{KeyId: f.keyID, Healthz: "ok"}, nil
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("failed to generate random bytes: %v", err)
+	}
+	return b
+}
+
+func TestEnvelopeTransformerRoundTrip(t *testing.T) {
+	for _, kdfEnabled := range []bool{false, true} {
+		t.Run(map[bool]string{false: "kdf-disabled", true: "kdf-enabled"}[kdfEnabled], func(t *testing.T) {
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, KMSv2KDFFeature, kdfEnabled)
+
+			seedLen := 32
+			svc := &fakeEnvelopeService{keyID: "1"}
+			seed := randomBytes(t, seedLen)
+			transformer := NewEnvelopeTransformer(svc, "test-provider", func(context.Context) (State, error) {
+				return State{KeyID: svc.keyID, EncryptedDEK: seed}, nil
+			}, kmstypes.Algorithm_AES_GCM)
+
+			ctx := context.Background()
+			dataCtx := value.DefaultContext("authenticated-context")
+			plaintext := []byte("super secret value")
+
+			encrypted, err := transformer.TransformToStorage(ctx, plaintext, dataCtx)
+			if err != nil {
+				t.Fatalf("TransformToStorage failed: %v", err)
+			}
+			decrypted, _, err := transformer.TransformFromStorage(ctx, encrypted, dataCtx)
+			if err != nil {
+				t.Fatalf("TransformFromStorage failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatalf("round trip mismatch: got %q, want %q", decrypted, plaintext)
+			}
+		})
+	}
+}
+
+func TestEnvelopeTransformerKDFDerivesDistinctKeysPerObject(t *testing.T) {
+	featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, KMSv2KDFFeature, true)
+
+	svc := &fakeEnvelopeService{keyID: "1"}
+	seed := randomBytes(t, 32)
+	transformer := NewEnvelopeTransformer(svc, "test-provider", func(context.Context) (State, error) {
+		return State{KeyID: svc.keyID, EncryptedDEK: seed}, nil
+	}, kmstypes.Algorithm_AES_GCM)
+
+	ctx := context.Background()
+	dataCtx := value.DefaultContext("ctx")
+
+	first, err := transformer.TransformToStorage(ctx, []byte("value-a"), dataCtx)
+	if err != nil {
+		t.Fatalf("TransformToStorage failed: %v", err)
+	}
+	second, err := transformer.TransformToStorage(ctx, []byte("value-a"), dataCtx)
+	if err != nil {
+		t.Fatalf("TransformToStorage failed: %v", err)
+	}
+
+	if bytes.Equal(first, second) {
+		t.Fatal("expected two objects encrypted under the same seed to have distinct ciphertext, since each derives a unique key")
+	}
+}
+
+// TestEnvelopeTransformerDEKReuse covers the non-KDF path, where every
+// object sharing a DEK must still round-trip under each pluggable
+// Algorithm, including AES-GCM-SIV's relaxed nonce-reuse guarantees.
+func TestEnvelopeTransformerDEKReuse(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		algorithm kmstypes.Algorithm
+	}{
+		{name: "AES-GCM", algorithm: kmstypes.Algorithm_AES_GCM},
+		{name: "AES-GCM-SIV", algorithm: kmstypes.Algorithm_AES_GCM_SIV},
+		{name: "ChaCha20-Poly1305", algorithm: kmstypes.Algorithm_CHACHA20_POLY1305},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeEnvelopeService{keyID: "1"}
+			dek := randomBytes(t, 32)
+			transformer := NewEnvelopeTransformer(svc, "test-provider", func(context.Context) (State, error) {
+				return State{KeyID: svc.keyID, EncryptedDEK: dek}, nil
+			}, tc.algorithm)
+
+			ctx := context.Background()
+			dataCtx := value.DefaultContext("ctx")
+
+			var encrypted [][]byte
+			for i := 0; i < 5; i++ {
+				out, err := transformer.TransformToStorage(ctx, []byte("same DEK, reused"), dataCtx)
+				if err != nil {
+					t.Fatalf("TransformToStorage failed: %v", err)
+				}
+				encrypted = append(encrypted, out)
+			}
+
+			for i, e := range encrypted {
+				decrypted, _, err := transformer.TransformFromStorage(ctx, e, dataCtx)
+				if err != nil {
+					t.Fatalf("TransformFromStorage failed for write %d: %v", i, err)
+				}
+				if !bytes.Equal(decrypted, []byte("same DEK, reused")) {
+					t.Fatalf("write %d: round trip mismatch: got %q", i, decrypted)
+				}
+				for j := i + 1; j < len(encrypted); j++ {
+					if bytes.Equal(e, encrypted[j]) {
+						t.Fatalf("writes %d and %d under the same DEK produced identical ciphertext", i, j)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestEnvelopeTransformerKDFReadsCacheSeedByEncryptedDEK verifies that
+// TransformFromStorage in KDF mode reuses an unwrapped seed across every
+// object sealed under it, the same way the non-KDF path reuses a DEK, so a
+// single KMS Decrypt call serves every read that shares an EncryptedDEK.
+func TestEnvelopeTransformerKDFReadsCacheSeedByEncryptedDEK(t *testing.T) {
+	featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, KMSv2KDFFeature, true)
+
+	svc := &fakeEnvelopeService{keyID: "1"}
+	seed := randomBytes(t, 32)
+	transformer := NewEnvelopeTransformer(svc, "test-provider", func(context.Context) (State, error) {
+		return State{KeyID: svc.keyID, EncryptedDEK: seed}, nil
+	}, kmstypes.Algorithm_AES_GCM)
+
+	ctx := context.Background()
+	dataCtx := value.DefaultContext("ctx")
+
+	var encrypted [][]byte
+	for i := 0; i < 5; i++ {
+		out, err := transformer.TransformToStorage(ctx, []byte("same seed, reused"), dataCtx)
+		if err != nil {
+			t.Fatalf("TransformToStorage failed: %v", err)
+		}
+		encrypted = append(encrypted, out)
+	}
+	// the writes above unwrapped the seed into transformer's own cache; read
+	// through a second transformer whose cache is empty, and reset the
+	// counter, so the assertion below isolates reads.
+	reader := NewEnvelopeTransformer(svc, "test-provider", func(context.Context) (State, error) {
+		return State{KeyID: svc.keyID, EncryptedDEK: seed}, nil
+	}, kmstypes.Algorithm_AES_GCM)
+	svc.mu.Lock()
+	svc.decryptCalls = 0
+	svc.mu.Unlock()
+
+	for i, e := range encrypted {
+		decrypted, _, err := reader.TransformFromStorage(ctx, e, dataCtx)
+		if err != nil {
+			t.Fatalf("TransformFromStorage failed for write %d: %v", i, err)
+		}
+		if !bytes.Equal(decrypted, []byte("same seed, reused")) {
+			t.Fatalf("write %d: round trip mismatch: got %q", i, decrypted)
+		}
+	}
+
+	if got := svc.decryptCallCount(); got != 1 {
+		t.Fatalf("expected exactly 1 Decrypt call across 5 reads sharing an EncryptedDEK, got %d", got)
+	}
+}
+
+// TestEnvelopeTransformerMixedAlgorithmReads verifies that objects written
+// under one Algorithm keep decrypting correctly after the provider is
+// reconfigured to seal new writes with a different Algorithm, since
+// TransformFromStorage dispatches on each object's own stored field rather
+// than the transformer's current setting.
+func TestEnvelopeTransformerMixedAlgorithmReads(t *testing.T) {
+	svc := &fakeEnvelopeService{keyID: "1"}
+	dek := randomBytes(t, 32)
+	stateFunc := func(context.Context) (State, error) {
+		return State{KeyID: svc.keyID, EncryptedDEK: dek}, nil
+	}
+
+	ctx := context.Background()
+	dataCtx := value.DefaultContext("ctx")
+
+	gcmTransformer := NewEnvelopeTransformer(svc, "test-provider", stateFunc, kmstypes.Algorithm_AES_GCM)
+	gcmEncrypted, err := gcmTransformer.TransformToStorage(ctx, []byte("written under AES-GCM"), dataCtx)
+	if err != nil {
+		t.Fatalf("TransformToStorage failed: %v", err)
+	}
+
+	// reconfigure to ChaCha20-Poly1305, as if the provider's algorithm field
+	// changed and the apiserver reloaded.
+	chachaTransformer := NewEnvelopeTransformer(svc, "test-provider", stateFunc, kmstypes.Algorithm_CHACHA20_POLY1305)
+
+	decrypted, _, err := chachaTransformer.TransformFromStorage(ctx, gcmEncrypted, dataCtx)
+	if err != nil {
+		t.Fatalf("expected the AES-GCM-encrypted object to still decrypt after switching to ChaCha20-Poly1305: %v", err)
+	}
+	if !bytes.Equal(decrypted, []byte("written under AES-GCM")) {
+		t.Fatalf("decrypted mismatch: got %q", decrypted)
+	}
+}
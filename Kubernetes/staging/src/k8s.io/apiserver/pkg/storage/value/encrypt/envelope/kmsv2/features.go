@@ -0,0 +1,38 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kmsv2
+
+import (
+	"k8s.io/apimachinery/pkg/util/runtime"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	featuregate "k8s.io/component-base/featuregate"
+)
+
+// KMSv2KDFFeature gates deriving a fresh per-object AES key via HKDF-SHA256
+// from the KMS-returned seed, instead of using the KMS-returned bytes
+// directly as the DEK. It is alpha and defaults to off so existing KMSv2
+// deployments keep writing the historical EncryptedObject layout until
+// operators opt in.
+const KMSv2KDFFeature featuregate.Feature = "KMSv2KDF"
+
+var defaultKMSv2FeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	KMSv2KDFFeature: {Default: false, PreRelease: featuregate.Alpha},
+}
+
+func init() {
+	runtime.Must(utilfeature.DefaultMutableFeatureGate.Add(defaultKMSv2FeatureGates))
+}
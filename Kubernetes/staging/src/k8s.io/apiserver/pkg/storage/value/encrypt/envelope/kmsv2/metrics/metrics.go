@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics registers the Prometheus metrics emitted by KMSv2 envelope
+// encryption: the DEK cache hit rate, calls made to the KMS plugin, and the
+// key IDs it reports. It is a sibling of the kmsv2 and encryptionconfig
+// packages, both of which record into it, rather than a member of either,
+// since neither package should import the other just to reach these
+// counters.
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	dekCacheHitsTotal = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name:           "apiserver_envelope_encryption_dek_cache_hits_total",
+			Help:           "Total number of KMSv2 envelope transformations served by the cached DEK instead of requesting a new one from the KMS plugin.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	dekCacheMissesTotal = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name:           "apiserver_envelope_encryption_dek_cache_misses_total",
+			Help:           "Total number of KMSv2 envelope transformations that had to wrap a freshly generated DEK because none was cached, the plugin's key ID changed, or proactive rotation was due.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+	kmsOperationsTotal = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name:           "apiserver_envelope_encryption_kms_operations_total",
+			Help:           "Total number of calls made to a KMSv2 plugin, by operation and outcome.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation", "status"},
+	)
+	keyIDHashTotal = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name:           "apiserver_envelope_encryption_key_id_hash_total",
+			Help:           "Total number of times a KMSv2 plugin reported each key ID, identified by the hex-encoded SHA-256 hash of the key ID rather than the key ID itself.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"key_id_hash"},
+	)
+	invalidKeyIDFromStatusTotal = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name:           "apiserver_envelope_encryption_invalid_key_id_from_status_total",
+			Help:           "Total number of times a KMSv2 plugin's Status call reported an empty or otherwise invalid key ID.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(dekCacheHitsTotal)
+	legacyregistry.MustRegister(dekCacheMissesTotal)
+	legacyregistry.MustRegister(kmsOperationsTotal)
+	legacyregistry.MustRegister(keyIDHashTotal)
+	legacyregistry.MustRegister(invalidKeyIDFromStatusTotal)
+}
+
+// RecordDEKCacheHit increments the DEK cache hit counter.
+func RecordDEKCacheHit() {
+	dekCacheHitsTotal.Inc()
+}
+
+// RecordDEKCacheMiss increments the DEK cache miss counter.
+func RecordDEKCacheMiss() {
+	dekCacheMissesTotal.Inc()
+}
+
+// RecordKMSOperation increments the per-operation KMS call counter.
+// operation is one of "status", "encrypt" or "decrypt"; status is "success"
+// or "error".
+func RecordKMSOperation(operation, status string) {
+	kmsOperationsTotal.WithLabelValues(operation, status).Inc()
+}
+
+// RecordKeyIDHash increments the key ID hash counter for keyID, hashing it
+// first so the raw key ID, which may be sensitive, never appears in a
+// metric label.
+func RecordKeyIDHash(keyID string) {
+	keyIDHashTotal.WithLabelValues(HashKeyID(keyID)).Inc()
+}
+
+// RecordInvalidKeyIDFromStatus increments the invalid-key-ID counter.
+func RecordInvalidKeyIDFromStatus() {
+	invalidKeyIDFromStatusTotal.Inc()
+}
+
+// HashKeyID returns the hex-encoded SHA-256 hash of keyID, the same value
+// recorded as the key_id_hash label by RecordKeyIDHash, so callers (for
+// example, tests) can compute the expected label value independently.
+func HashKeyID(keyID string) string {
+	sum := sha256.Sum256([]byte(keyID))
+	return hex.EncodeToString(sum[:])
+}
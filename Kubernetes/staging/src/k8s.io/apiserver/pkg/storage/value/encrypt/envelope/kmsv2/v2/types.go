@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v2 holds the wire format persisted to etcd for every value
+// encrypted through the KMSv2 envelope transformer.
+package v2
+
+import (
+	fmt "fmt"
+
+	proto "github.com/gogo/protobuf/proto"
+)
+
+// Algorithm identifies the AEAD cipher used to seal EncryptedData.
+type Algorithm int32
+
+const (
+	// Algorithm_AES_GCM is the default, unchanged cipher: a 96-bit nonce
+	// (4-byte random prefix + 8-byte little-endian counter) and a DEK used
+	// directly as the AES-GCM key.
+	Algorithm_AES_GCM Algorithm = 0
+	// Algorithm_AES_GCM_SIV is misuse-resistant, permitting safe DEK reuse
+	// without per-write nonce coordination.
+	Algorithm_AES_GCM_SIV Algorithm = 1
+	// Algorithm_CHACHA20_POLY1305 is for environments without AES-NI.
+	Algorithm_CHACHA20_POLY1305 Algorithm = 2
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case Algorithm_AES_GCM:
+		return "AES-GCM"
+	case Algorithm_AES_GCM_SIV:
+		return "AES-GCM-SIV"
+	case Algorithm_CHACHA20_POLY1305:
+		return "ChaCha20-Poly1305"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int32(a))
+	}
+}
+
+// EncryptedObject is the representation of data stored in etcd after
+// envelope encryption.
+type EncryptedObject struct {
+	// EncryptedData is the encrypted data.
+	EncryptedData []byte `protobuf:"bytes,1,opt,name=encryptedData,proto3" json:"encryptedData,omitempty"`
+	// KeyID is the KMS key ID used for encryption operations.
+	KeyID string `protobuf:"bytes,2,opt,name=keyID,proto3" json:"keyID,omitempty"`
+	// EncryptedDEK is the encrypted DEK, or, when KeyDerivationInfo is set,
+	// the encrypted key-derivation seed.
+	EncryptedDEK []byte `protobuf:"bytes,3,opt,name=encryptedDEK,proto3" json:"encryptedDEK,omitempty"`
+	// Annotations is additional metadata that was provided by the KMS
+	// plugin.
+	Annotations map[string][]byte `protobuf:"bytes,4,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// KeyDerivationInfo, when non-empty, indicates EncryptedDEK is a seed
+	// that must be run through HKDF-SHA256 together with this value to
+	// produce the per-object AES key, rather than used directly as a DEK.
+	// Gated by the KMSv2KDF feature.
+	KeyDerivationInfo []byte `protobuf:"bytes,5,opt,name=keyDerivationInfo,proto3" json:"keyDerivationInfo,omitempty"`
+	// Algorithm identifies the AEAD cipher EncryptedData was sealed with.
+	// Zero value (Algorithm_AES_GCM) preserves the historical nonce layout
+	// so objects written before this field existed keep decrypting.
+	Algorithm Algorithm `protobuf:"varint,6,opt,name=algorithm,proto3,enum=v2.Algorithm" json:"algorithm,omitempty"`
+}
+
+func (m *EncryptedObject) Reset()         { *m = EncryptedObject{} }
+func (m *EncryptedObject) String() string { return proto.CompactTextString(m) }
+func (*EncryptedObject) ProtoMessage()    {}
@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migrator re-encrypts resources that are already stored under an
+// encryption provider which is no longer the active one. Neither a
+// configuration reload (see k8s.io/apiserver/pkg/server/options/encryptionconfig)
+// nor a forced KMSv2 key rotation (see cachingKMSv2State.rotate in that same
+// package) rewrites existing rows; they only change what new writes use. A
+// Driver closes that gap by listing every resource covered by the active
+// EncryptionConfiguration and issuing a no-op update through the normal
+// write path for any object whose stored prefix doesn't already match the
+// provider that would encrypt it today, so the storage layer re-encrypts it
+// as a side effect.
+package migrator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"golang.org/x/time/rate"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/migrator/metrics"
+)
+
+// DefaultPageSize is how many objects a Driver lists per ResourceLister.List
+// call when none is configured.
+const DefaultPageSize = 500
+
+// Object is the minimal view of a stored resource the migrator needs to
+// decide whether it requires re-encryption and to issue the no-op update
+// that triggers it.
+type Object struct {
+	// Namespace is empty for cluster-scoped resources.
+	Namespace string
+	// Name identifies the object within Namespace.
+	Name string
+	// ResourceVersion is the object's current resource version, required so
+	// TouchUpdate can issue an update that fails instead of silently
+	// clobbering a concurrent write.
+	ResourceVersion string
+	// StoredPrefix is the value.PrefixTransformer prefix the object is
+	// currently persisted under, read off the raw stored bytes.
+	StoredPrefix []byte
+}
+
+// ResourceLister pages through every stored instance of a GroupResource. An
+// implementation wraps the apiserver's real storage.Interface List, passing
+// the continue token straight through so pagination behaves exactly as it
+// does for any other List call.
+type ResourceLister interface {
+	List(ctx context.Context, gr schema.GroupResource, continueToken string, limit int64) (objects []Object, nextContinueToken string, err error)
+}
+
+// ResourceUpdater issues a no-op update to a single object purely to drive
+// it back through the write path so storage re-encrypts it under whichever
+// provider is active for its GroupResource today.
+type ResourceUpdater interface {
+	TouchUpdate(ctx context.Context, gr schema.GroupResource, namespace, name, resourceVersion string) error
+}
+
+// ProgressStore records and resumes per-resource migration progress across
+// restarts. An implementation backs this with a ConfigMap (or equivalent)
+// keyed by GroupResource.
+type ProgressStore interface {
+	// Load returns the continue token to resume gr's migration from, and
+	// whether gr has already been fully migrated. A zero-value continueToken
+	// with done == false means start from the beginning.
+	Load(ctx context.Context, gr schema.GroupResource) (continueToken string, done bool, err error)
+	// Save persists gr's migration progress so a crash can resume from it.
+	Save(ctx context.Context, gr schema.GroupResource, continueToken string, done bool) error
+}
+
+// Driver runs the re-encryption migration described in the package doc for
+// a set of GroupResources.
+type Driver struct {
+	Lister   ResourceLister
+	Updater  ResourceUpdater
+	Progress ProgressStore
+
+	// ActivePrefix returns the value.PrefixTransformer prefix objects of gr
+	// are currently written under, and whether gr is covered by the active
+	// EncryptionConfiguration at all. An object whose StoredPrefix already
+	// equals this is left alone.
+	ActivePrefix func(gr schema.GroupResource) (prefix []byte, ok bool)
+
+	// Limiter bounds the combined QPS of List and TouchUpdate calls issued
+	// against the apiserver's storage layer, so a migration run doesn't
+	// starve normal API traffic. A nil Limiter means unlimited.
+	Limiter *rate.Limiter
+
+	// PageSize is how many objects are requested per List call. Defaults to
+	// DefaultPageSize if zero.
+	PageSize int64
+}
+
+// Migrate re-encrypts every object of every GroupResource in resources that
+// isn't already stored under its active provider's prefix, resuming each
+// from wherever ProgressStore last left off. It returns the first error
+// encountered listing or updating an object; resources after the failing
+// one are not attempted on that call, but an already-completed prefix is
+// skipped again on the next call via ProgressStore.
+func (d *Driver) Migrate(ctx context.Context, resources []schema.GroupResource) error {
+	for _, gr := range resources {
+		if err := d.migrateResource(ctx, gr); err != nil {
+			return fmt.Errorf("failed to migrate resource %v: %w", gr, err)
+		}
+	}
+	return nil
+}
+
+func (d *Driver) migrateResource(ctx context.Context, gr schema.GroupResource) error {
+	activePrefix, ok := d.ActivePrefix(gr)
+	if !ok {
+		return fmt.Errorf("no active encryption provider configured for resource %v", gr)
+	}
+
+	continueToken, done, err := d.Progress.Load(ctx, gr)
+	if err != nil {
+		return fmt.Errorf("failed to load migration progress: %w", err)
+	}
+	if done {
+		return nil
+	}
+
+	pageSize := d.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	for {
+		if err := d.wait(ctx); err != nil {
+			return err
+		}
+		objects, nextContinueToken, err := d.Lister.List(ctx, gr, continueToken, pageSize)
+		if err != nil {
+			return fmt.Errorf("failed to list resource %v: %w", gr, err)
+		}
+
+		for _, obj := range objects {
+			if bytes.Equal(obj.StoredPrefix, activePrefix) {
+				metrics.RecordObjectSkipped(gr.String())
+				continue
+			}
+			if err := d.wait(ctx); err != nil {
+				return err
+			}
+			if err := d.Updater.TouchUpdate(ctx, gr, obj.Namespace, obj.Name, obj.ResourceVersion); err != nil {
+				metrics.RecordObjectFailed(gr.String())
+				return fmt.Errorf("failed to re-encrypt %v %s/%s: %w", gr, obj.Namespace, obj.Name, err)
+			}
+			metrics.RecordObjectMigrated(gr.String())
+		}
+
+		continueToken = nextContinueToken
+		if err := d.Progress.Save(ctx, gr, continueToken, len(continueToken) == 0); err != nil {
+			return fmt.Errorf("failed to save migration progress: %w", err)
+		}
+		if len(continueToken) == 0 {
+			return nil
+		}
+	}
+}
+
+// wait blocks until Limiter permits another request, or ctx is done.
+func (d *Driver) wait(ctx context.Context) error {
+	if d.Limiter == nil {
+		return nil
+	}
+	return d.Limiter.Wait(ctx)
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakePodStore is an in-memory stand-in for the apiserver's real storage
+// layer: it tracks, per pod, which KMSv2 keyID prefix last encrypted it and
+// how many times it's been written.
+type fakePodStore struct {
+	keyIDPrefix map[string][]byte
+	writes      map[string]int
+	activeKeyID string
+}
+
+func newFakePodStore(n int, initialKeyID string) *fakePodStore {
+	s := &fakePodStore{
+		keyIDPrefix: make(map[string][]byte, n),
+		writes:      make(map[string]int, n),
+		activeKeyID: initialKeyID,
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("pod-%d", i)
+		s.keyIDPrefix[name] = []byte(initialKeyID)
+	}
+	return s
+}
+
+func (s *fakePodStore) List(ctx context.Context, gr schema.GroupResource, continueToken string, limit int64) ([]Object, string, error) {
+	names := make([]string, 0, len(s.keyIDPrefix))
+	for name := range s.keyIDPrefix {
+		names = append(names, name)
+	}
+	// deterministic order so paging is stable across calls.
+	sort.Strings(names)
+
+	start := 0
+	if len(continueToken) > 0 {
+		parsed, err := strconv.Atoi(continueToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid continue token %q: %w", continueToken, err)
+		}
+		start = parsed
+	}
+	if start >= len(names) {
+		return nil, "", nil
+	}
+	end := start + int(limit)
+	if end > len(names) {
+		end = len(names)
+	}
+
+	var out []Object
+	for _, name := range names[start:end] {
+		out = append(out, Object{
+			Name:            name,
+			ResourceVersion: strconv.Itoa(s.writes[name]),
+			StoredPrefix:    s.keyIDPrefix[name],
+		})
+	}
+
+	next := ""
+	if end < len(names) {
+		next = strconv.Itoa(end)
+	}
+	return out, next, nil
+}
+
+func (s *fakePodStore) TouchUpdate(ctx context.Context, gr schema.GroupResource, namespace, name, resourceVersion string) error {
+	if got := strconv.Itoa(s.writes[name]); got != resourceVersion {
+		return fmt.Errorf("stale resource version for %s: got %s, want %s", name, resourceVersion, got)
+	}
+	s.writes[name]++
+	s.keyIDPrefix[name] = []byte(s.activeKeyID)
+	return nil
+}
+
+func (s *fakePodStore) activePrefix(schema.GroupResource) ([]byte, bool) {
+	return []byte(s.activeKeyID), true
+}
+
+// fakeProgressStore is an in-memory ProgressStore, standing in for the
+// ConfigMap-backed implementation the real controller would use.
+type fakeProgressStore struct {
+	continueToken map[schema.GroupResource]string
+	done          map[schema.GroupResource]bool
+}
+
+func newFakeProgressStore() *fakeProgressStore {
+	return &fakeProgressStore{
+		continueToken: make(map[schema.GroupResource]string),
+		done:          make(map[schema.GroupResource]bool),
+	}
+}
+
+func (p *fakeProgressStore) Load(ctx context.Context, gr schema.GroupResource) (string, bool, error) {
+	return p.continueToken[gr], p.done[gr], nil
+}
+
+func (p *fakeProgressStore) Save(ctx context.Context, gr schema.GroupResource, continueToken string, done bool) error {
+	p.continueToken[gr] = continueToken
+	p.done[gr] = done
+	return nil
+}
+
+func TestDriverMigratesAllObjectsAfterRotation(t *testing.T) {
+	const numPods = 1000
+	pods := schema.GroupResource{Resource: "pods"}
+	store := newFakePodStore(numPods, "1")
+
+	// bump the plugin's active keyID, simulating a master-secret rotation;
+	// every pod's stored prefix ("1") is now stale.
+	store.activeKeyID = "2"
+
+	d := &Driver{
+		Lister:       store,
+		Updater:      store,
+		Progress:     newFakeProgressStore(),
+		ActivePrefix: store.activePrefix,
+		PageSize:     64,
+	}
+
+	if err := d.Migrate(context.Background(), []schema.GroupResource{pods}); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	for name, prefix := range store.keyIDPrefix {
+		if string(prefix) != "2" {
+			t.Fatalf("pod %s: got prefix %q, want \"2\"", name, prefix)
+		}
+		if got := store.writes[name]; got != 1 {
+			t.Fatalf("pod %s: got %d writes, want exactly 1", name, got)
+		}
+	}
+}
+
+func TestDriverSkipsObjectsAlreadyUnderActivePrefix(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	store := newFakePodStore(10, "1")
+	// no rotation: active keyID already matches every pod's stored prefix.
+
+	d := &Driver{
+		Lister:       store,
+		Updater:      store,
+		Progress:     newFakeProgressStore(),
+		ActivePrefix: store.activePrefix,
+		PageSize:     3,
+	}
+
+	if err := d.Migrate(context.Background(), []schema.GroupResource{pods}); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	for name, n := range store.writes {
+		if n != 0 {
+			t.Fatalf("pod %s: got %d writes, want 0 since it already matched the active prefix", name, n)
+		}
+	}
+}
+
+func TestDriverResumesFromSavedProgress(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	store := newFakePodStore(20, "1")
+	store.activeKeyID = "2"
+
+	progress := newFakeProgressStore()
+	// pretend a previous run already migrated and checkpointed past the
+	// first 10 (lexicographically ordered) pods before crashing.
+	progress.continueToken[pods] = "10"
+
+	d := &Driver{
+		Lister:       store,
+		Updater:      store,
+		Progress:     progress,
+		ActivePrefix: store.activePrefix,
+		PageSize:     5,
+	}
+
+	if err := d.Migrate(context.Background(), []schema.GroupResource{pods}); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	names := make([]string, 0, len(store.keyIDPrefix))
+	for name := range store.keyIDPrefix {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i < 10 {
+			if store.writes[name] != 0 {
+				t.Fatalf("pod %s: expected to be skipped as already migrated before resume, got %d writes", name, store.writes[name])
+			}
+			continue
+		}
+		if store.writes[name] != 1 {
+			t.Fatalf("pod %s: expected exactly 1 write after resuming migration, got %d", name, store.writes[name])
+		}
+	}
+}